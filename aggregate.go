@@ -0,0 +1,283 @@
+package query
+
+import (
+	"cmp"
+
+	"github.com/brnsampson/optional"
+)
+
+// Aggregator accumulates a result over the records it is given. Use Aggregate or Collection.Aggregate to feed it the
+// records matching a query. Like SQL aggregates, the aggregators over a field skip records where the field is None.
+type Aggregator[T comparable] interface {
+	Add(record T)
+}
+
+// Number is the set of types which can be summed and averaged.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Aggregate feeds every record matching the query to all of the aggregators in a single pass over the records.
+func Aggregate[T comparable](records []T, query Query[T], aggregators ...Aggregator[T]) error {
+	for _, r := range records {
+		matched, err := query.Matches(r)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+		for _, a := range aggregators {
+			a.Add(r)
+		}
+	}
+	return nil
+}
+
+// Aggregate feeds every record in the collection which matches the query to all of the aggregators. Records are fed
+// in id order.
+func (c *Collection[T]) Aggregate(query Query[T], aggregators ...Aggregator[T]) error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	ids, err := c.find(nil, query)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		for _, a := range aggregators {
+			a.Add(c.records[id])
+		}
+	}
+	return nil
+}
+
+// CountAggregate counts records. When it is created with CountField it only counts records where the field is not
+// None, like COUNT(column) in SQL.
+type CountAggregate[T comparable] struct {
+	isNone func(T) bool
+	count  int
+}
+
+func Count[T comparable]() *CountAggregate[T] {
+	return &CountAggregate[T]{}
+}
+
+func CountField[T comparable, F comparable](field Field[T, F]) *CountAggregate[T] {
+	return &CountAggregate[T]{isNone: func(record T) bool { return field.Get(record).IsNone() }}
+}
+
+func (a *CountAggregate[T]) Add(record T) {
+	if a.isNone != nil && a.isNone(record) {
+		return
+	}
+	a.count++
+}
+
+func (a *CountAggregate[T]) Result() int {
+	return a.count
+}
+
+// SumAggregate sums a numeric field. The result is None if no record had a value for the field. Integer sums can
+// overflow the same way any other integer arithmetic can.
+type SumAggregate[T comparable, F Number] struct {
+	field Field[T, F]
+	sum   F
+	count int
+}
+
+func Sum[T comparable, F Number](field Field[T, F]) *SumAggregate[T, F] {
+	return &SumAggregate[T, F]{field: field}
+}
+
+func (a *SumAggregate[T, F]) Add(record T) {
+	value := a.field.Get(record)
+	if value.IsNone() {
+		return
+	}
+	a.sum += value.UnsafeUnwrap()
+	a.count++
+}
+
+func (a *SumAggregate[T, F]) Result() optional.Optional[F] {
+	if a.count == 0 {
+		return optional.None[F]().AsRef()
+	}
+	return optional.NewOption(a.sum).AsRef()
+}
+
+// AvgAggregate averages a numeric field. The result is None if no record had a value for the field.
+type AvgAggregate[T comparable, F Number] struct {
+	field Field[T, F]
+	sum   float64
+	count int
+}
+
+func Avg[T comparable, F Number](field Field[T, F]) *AvgAggregate[T, F] {
+	return &AvgAggregate[T, F]{field: field}
+}
+
+func (a *AvgAggregate[T, F]) Add(record T) {
+	value := a.field.Get(record)
+	if value.IsNone() {
+		return
+	}
+	a.sum += float64(value.UnsafeUnwrap())
+	a.count++
+}
+
+func (a *AvgAggregate[T, F]) Result() optional.Optional[float64] {
+	if a.count == 0 {
+		return optional.None[float64]().AsRef()
+	}
+	return optional.NewOption(a.sum / float64(a.count)).AsRef()
+}
+
+// ExtremeAggregate tracks the minimum or maximum of an ordered field. The result is None if no record had a value for
+// the field. Values are ordered with cmp.Compare, so NaN is smaller than any other float.
+type ExtremeAggregate[T comparable, F cmp.Ordered] struct {
+	field Field[T, F]
+	max   bool
+	value F
+	seen  bool
+}
+
+func Min[T comparable, F cmp.Ordered](field Field[T, F]) *ExtremeAggregate[T, F] {
+	return &ExtremeAggregate[T, F]{field: field}
+}
+
+func Max[T comparable, F cmp.Ordered](field Field[T, F]) *ExtremeAggregate[T, F] {
+	return &ExtremeAggregate[T, F]{field: field, max: true}
+}
+
+func (a *ExtremeAggregate[T, F]) Add(record T) {
+	value := a.field.Get(record)
+	if value.IsNone() {
+		return
+	}
+	v := value.UnsafeUnwrap()
+	if !a.seen {
+		a.value = v
+		a.seen = true
+	} else if c := cmp.Compare(v, a.value); (a.max && c > 0) || (!a.max && c < 0) {
+		a.value = v
+	}
+}
+
+func (a *ExtremeAggregate[T, F]) Result() optional.Optional[F] {
+	if !a.seen {
+		return optional.None[F]().AsRef()
+	}
+	return optional.NewOption(a.value).AsRef()
+}
+
+// DistinctAggregate collects the distinct values of a field in the order they were first seen.
+type DistinctAggregate[T comparable, F comparable] struct {
+	field  Field[T, F]
+	seen   map[F]struct{}
+	values []F
+}
+
+func Distinct[T comparable, F comparable](field Field[T, F]) *DistinctAggregate[T, F] {
+	return &DistinctAggregate[T, F]{field: field, seen: make(map[F]struct{})}
+}
+
+func (a *DistinctAggregate[T, F]) Add(record T) {
+	value := a.field.Get(record)
+	if value.IsNone() {
+		return
+	}
+	v := value.UnsafeUnwrap()
+	if _, ok := a.seen[v]; ok {
+		return
+	}
+	a.seen[v] = struct{}{}
+	a.values = append(a.values, v)
+}
+
+func (a *DistinctAggregate[T, F]) Result() []F {
+	return a.values
+}
+
+// ProjectAggregate collects the value of a field for every record, in the order the records were seen.
+type ProjectAggregate[T comparable, F comparable] struct {
+	field  Field[T, F]
+	values []F
+}
+
+func Project[T comparable, F comparable](field Field[T, F]) *ProjectAggregate[T, F] {
+	return &ProjectAggregate[T, F]{field: field}
+}
+
+func (a *ProjectAggregate[T, F]) Add(record T) {
+	value := a.field.Get(record)
+	if value.IsNone() {
+		return
+	}
+	a.values = append(a.values, value.UnsafeUnwrap())
+}
+
+func (a *ProjectAggregate[T, F]) Result() []F {
+	return a.values
+}
+
+// Group is the aggregate for all records sharing the same key. Records where the key field is None are grouped
+// together under a None key, like GROUP BY in SQL.
+type Group[K comparable, A any] struct {
+	Key       optional.Optional[K]
+	Aggregate A
+}
+
+// GroupAggregate splits records into groups by the value of a field and feeds each group to its own aggregator, which
+// is created by calling newAggregate the first time a key is seen.
+type GroupAggregate[T comparable, K comparable, A Aggregator[T]] struct {
+	field        Field[T, K]
+	newAggregate func() A
+	index        map[K]int
+	none         int
+	groups       []Group[K, A]
+}
+
+func GroupBy[T comparable, K comparable, A Aggregator[T]](field Field[T, K], newAggregate func() A) *GroupAggregate[T, K, A] {
+	return &GroupAggregate[T, K, A]{field: field, newAggregate: newAggregate, index: make(map[K]int), none: -1}
+}
+
+func (a *GroupAggregate[T, K, A]) Add(record T) {
+	key := a.field.Get(record)
+
+	var i int
+	var ok bool
+	if key.IsNone() {
+		i, ok = a.none, a.none >= 0
+		if !ok {
+			a.none = len(a.groups)
+		}
+	} else {
+		i, ok = a.index[key.UnsafeUnwrap()]
+		if !ok {
+			a.index[key.UnsafeUnwrap()] = len(a.groups)
+		}
+	}
+	if !ok {
+		i = len(a.groups)
+		a.groups = append(a.groups, Group[K, A]{key, a.newAggregate()})
+	}
+	a.groups[i].Aggregate.Add(record)
+}
+
+// Result returns the groups in the order their keys were first seen.
+func (a *GroupAggregate[T, K, A]) Result() []Group[K, A] {
+	return a.groups
+}
+
+// Get returns the aggregate for a single key.
+func (a *GroupAggregate[T, K, A]) Get(key K) (A, bool) {
+	i, ok := a.index[key]
+	if !ok {
+		var zero A
+		return zero, false
+	}
+	return a.groups[i].Aggregate, true
+}
@@ -0,0 +1,106 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestAggregate(t *testing.T) {
+	records := sortRecords()
+	q := balanceField.Where(smartquery.AtLeast(2).AsRef()).AsRef()
+
+	count := smartquery.Count[testStruct]()
+	countStars := smartquery.CountField(starsField)
+	sum := smartquery.Sum(balanceField)
+	sumStars := smartquery.Sum(starsField)
+	avg := smartquery.Avg(starsField)
+	least := smartquery.Min(starsField)
+	most := smartquery.Max(nameField)
+	distinct := smartquery.Distinct(balanceField)
+	project := smartquery.Project(nameField)
+
+	err := smartquery.Aggregate(records, q, count, countStars, sum, sumStars, avg, least, most, distinct, project)
+	assert.NilError(t, err)
+
+	// a, c and e match. e has no stars.
+	assert.Equal(t, count.Result(), 3)
+	assert.Equal(t, countStars.Result(), 2)
+	assert.Equal(t, sum.Result().UnsafeUnwrap(), 7)
+	assert.Equal(t, sumStars.Result().UnsafeUnwrap(), 4)
+	assert.Equal(t, avg.Result().UnsafeUnwrap(), 2.0)
+	assert.Equal(t, least.Result().UnsafeUnwrap(), 1)
+	assert.Equal(t, most.Result().UnsafeUnwrap(), "e")
+	assert.DeepEqual(t, distinct.Result(), []int{2, 3})
+	assert.DeepEqual(t, project.Result(), []string{"a", "c", "e"})
+}
+
+func TestAggregateNoValues(t *testing.T) {
+	records := sortRecords()
+	q := starsField.Where(smartquery.None(0).AsRef()).AsRef()
+
+	count := smartquery.Count[testStruct]()
+	sum := smartquery.Sum(starsField)
+	avg := smartquery.Avg(starsField)
+	least := smartquery.Min(starsField)
+	most := smartquery.Max(starsField)
+
+	err := smartquery.Aggregate(records, q, count, sum, avg, least, most)
+	assert.NilError(t, err)
+
+	// Like SQL, aggregates over only None values are None
+	assert.Equal(t, count.Result(), 2)
+	assert.Assert(t, sum.Result().IsNone())
+	assert.Assert(t, avg.Result().IsNone())
+	assert.Assert(t, least.Result().IsNone())
+	assert.Assert(t, most.Result().IsNone())
+
+	broken := balanceField.Where(smartquery.Like(1).AsRef()).AsRef()
+	err = smartquery.Aggregate(records, broken, count)
+	assert.ErrorContains(t, err, "QueryError")
+}
+
+func TestGroupBy(t *testing.T) {
+	records := sortRecords()
+	groups := smartquery.GroupBy(starsField, func() *smartquery.SumAggregate[testStruct, int] {
+		return smartquery.Sum(balanceField)
+	})
+	byBalance := smartquery.GroupBy(balanceField, smartquery.Count[testStruct])
+
+	err := smartquery.Aggregate(records, smartquery.Always[testStruct]().AsRef(), groups, byBalance)
+	assert.NilError(t, err)
+
+	result := groups.Result()
+	assert.Equal(t, len(result), 4)
+	assert.Equal(t, result[0].Key.UnsafeUnwrap(), 1)
+	assert.Assert(t, result[1].Key.IsNone())
+	assert.Equal(t, result[1].Aggregate.Result().UnsafeUnwrap(), 4, "records with None stars are grouped together")
+
+	sum, ok := groups.Get(3)
+	assert.Assert(t, ok)
+	assert.Equal(t, sum.Result().UnsafeUnwrap(), 2)
+	_, ok = groups.Get(42)
+	assert.Assert(t, !ok)
+
+	count, ok := byBalance.Get(1)
+	assert.Assert(t, ok)
+	assert.Equal(t, count.Result(), 2)
+}
+
+func TestCollectionAggregate(t *testing.T) {
+	c := testCollection()
+	q := nameField.Where(smartquery.ExactString("tester-0").AsRef()).AsRef()
+
+	sum := smartquery.Sum(balanceField)
+	emails := smartquery.CountField(emailField)
+	err := c.Aggregate(q, sum, emails)
+	assert.NilError(t, err)
+	assert.Equal(t, sum.Result().UnsafeUnwrap(), 450)
+	assert.Equal(t, emails.Result(), 10)
+
+	none := smartquery.Max(balanceField)
+	err = c.Aggregate(nameField.Where(smartquery.ExactString("nobody").AsRef()).AsRef(), none)
+	assert.NilError(t, err)
+	assert.Assert(t, none.Result().IsNone())
+}
@@ -0,0 +1,535 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/brnsampson/optional"
+)
+
+// BatchQuery is implemented by queries which can match a whole slice of values faster than calling Matches for each
+// one, by working out everything which doesn't depend on the value once per batch. Use the Evaluate batch functions
+// rather than calling the methods directly so that other queries work too.
+//
+// The []bool methods set out[i] to whether values[i] matches, and out must be at least as long as values. The Bitmap
+// methods overwrite out: bit i is set if values[i] matches and every other bit is cleared, and out must have room for
+// len(values) bits, like a bitmap from NewBitmap(len(values)).
+//
+// And and Or evaluate each child over the whole batch and combine the bitmaps, so a child which returns an error for a
+// value that an earlier child already decided fails the batch where Matches would have returned a result.
+type BatchQuery[T comparable] interface {
+	Query[T]
+	MatchBatch(values []T, out []bool) error
+	MatchOptionBatch(values []optional.Optional[T], out []bool) error
+	MatchBitmap(values []T, out Bitmap) error
+	MatchOptionBitmap(values []optional.Optional[T], out Bitmap) error
+}
+
+// EvaluateBatch matches every value against a query. Queries which do not implement BatchQuery are evaluated one value
+// at a time, stopping at the first error.
+func EvaluateBatch[T comparable](query Query[T], values []T, out []bool) error {
+	if err := checkBatch(len(values), len(out)); err != nil {
+		return err
+	}
+	if bq, ok := query.(BatchQuery[T]); ok {
+		return bq.MatchBatch(values, out)
+	}
+	for i, v := range values {
+		matched, err := query.Matches(v)
+		if err != nil {
+			return err
+		}
+		out[i] = matched
+	}
+	return nil
+}
+
+// EvaluateOptionBatch is the optional version of EvaluateBatch.
+func EvaluateOptionBatch[T comparable](query Query[T], values []optional.Optional[T], out []bool) error {
+	if err := checkBatch(len(values), len(out)); err != nil {
+		return err
+	}
+	if bq, ok := query.(BatchQuery[T]); ok {
+		return bq.MatchOptionBatch(values, out)
+	}
+	for i, v := range values {
+		matched, err := query.MatchesOption(v)
+		if err != nil {
+			return err
+		}
+		out[i] = matched
+	}
+	return nil
+}
+
+// EvaluateBitmap is EvaluateBatch with the results written to a bitmap.
+func EvaluateBitmap[T comparable](query Query[T], values []T, out Bitmap) error {
+	if err := checkBatch(len(values), 64*len(out)); err != nil {
+		return err
+	}
+	if bq, ok := query.(BatchQuery[T]); ok {
+		return bq.MatchBitmap(values, out)
+	}
+	clear(out)
+	for i, v := range values {
+		matched, err := query.Matches(v)
+		if err != nil {
+			return err
+		}
+		out.put(i, matched)
+	}
+	return nil
+}
+
+// EvaluateOptionBitmap is the optional version of EvaluateBitmap.
+func EvaluateOptionBitmap[T comparable](query Query[T], values []optional.Optional[T], out Bitmap) error {
+	if err := checkBatch(len(values), 64*len(out)); err != nil {
+		return err
+	}
+	if bq, ok := query.(BatchQuery[T]); ok {
+		return bq.MatchOptionBitmap(values, out)
+	}
+	clear(out)
+	for i, v := range values {
+		matched, err := query.MatchesOption(v)
+		if err != nil {
+			return err
+		}
+		out.put(i, matched)
+	}
+	return nil
+}
+
+func checkBatch(values, room int) error {
+	if room < values {
+		return fmt.Errorf("QueryError: batch of %d values only has room for %d results", values, room)
+	}
+	return nil
+}
+
+// put sets or clears bit i, which must be within the bitmap.
+func (b Bitmap) put(i int, on bool) {
+	if on {
+		b[i/64] |= 1 << (uint(i) % 64)
+	} else {
+		b[i/64] &^= 1 << (uint(i) % 64)
+	}
+}
+
+// fill sets the first n bits and clears the rest.
+func (b Bitmap) fill(n int) {
+	clear(b)
+	for i := range n / 64 {
+		b[i] = ^uint64(0)
+	}
+	if n%64 != 0 {
+		b[n/64] = 1<<(uint(n)%64) - 1
+	}
+}
+
+// toBools unpacks the first len(out) bits.
+func (b Bitmap) toBools(out []bool) {
+	for i := range out {
+		out[i] = b.Has(i)
+	}
+}
+
+// fieldPlan is what a FieldQuery does to each value of a batch, worked out once for the whole batch.
+type fieldPlan[T comparable] struct {
+	none    bool // The result for None
+	some    bool // The result for every Some, unless compare is set
+	compare bool // Some matches if it is equal to value
+	value   T
+}
+
+func (q *FieldQuery[T]) plan() (fieldPlan[T], error) {
+	switch q.criteria {
+	case MatchAlways:
+		return fieldPlan[T]{none: true, some: true}, nil
+	case MatchNone:
+		return fieldPlan[T]{none: true}, nil
+	case MatchAny:
+		return fieldPlan[T]{some: true}, nil
+	case MatchExact:
+		if !q.some {
+			return fieldPlan[T]{none: true}, nil
+		}
+		return fieldPlan[T]{compare: true, value: q.test}, nil
+	case MatchLike:
+		return fieldPlan[T]{}, fmt.Errorf("QueryError: cannot perform MatchLike matches on generic type. Use StringQuery instead.")
+	case MatchGlob:
+		return fieldPlan[T]{}, fmt.Errorf("QueryError: cannot perform MatchGlob matches on generic type. Use StringQuery instead.")
+	}
+	return fieldPlan[T]{}, fmt.Errorf("QueryError: unsupported matching strategy: %d", q.criteria)
+}
+
+func (p *fieldPlan[T]) match(value T) bool {
+	if p.compare {
+		return value == p.value
+	}
+	return p.some
+}
+
+func (p *fieldPlan[T]) matchOption(value optional.Optional[T]) bool {
+	if value.IsNone() {
+		return p.none
+	}
+	return p.match(value.UnsafeUnwrap())
+}
+
+func (q *FieldQuery[T]) MatchBatch(values []T, out []bool) error {
+	p, err := q.plan()
+	if err != nil {
+		return err
+	}
+	out = out[:len(values)]
+	if !p.compare {
+		for i := range out {
+			out[i] = p.some
+		}
+		return nil
+	}
+	for i, v := range values {
+		out[i] = v == p.value
+	}
+	return nil
+}
+
+func (q *FieldQuery[T]) MatchOptionBatch(values []optional.Optional[T], out []bool) error {
+	p, err := q.plan()
+	if err != nil {
+		return err
+	}
+	for i, v := range values {
+		out[i] = p.matchOption(v)
+	}
+	return nil
+}
+
+func (q *FieldQuery[T]) MatchBitmap(values []T, out Bitmap) error {
+	p, err := q.plan()
+	if err != nil {
+		return err
+	}
+	if !p.compare {
+		if p.some {
+			out.fill(len(values))
+		} else {
+			clear(out)
+		}
+		return nil
+	}
+	clear(out)
+	for i, v := range values {
+		if v == p.value {
+			out[i/64] |= 1 << (uint(i) % 64)
+		}
+	}
+	return nil
+}
+
+func (q *FieldQuery[T]) MatchOptionBitmap(values []optional.Optional[T], out Bitmap) error {
+	p, err := q.plan()
+	if err != nil {
+		return err
+	}
+	clear(out)
+	for i, v := range values {
+		if p.matchOption(v) {
+			out[i/64] |= 1 << (uint(i) % 64)
+		}
+	}
+	return nil
+}
+
+// stringPlan is fieldPlan for StringQuery. Values are normalized before they are compared, matched to pattern or
+// handed to the fuzzy query.
+type stringPlan struct {
+	fieldPlan[string]
+	pattern       *regexp.Regexp
+	fuzzy         *StringQuery
+	normalization Normalization
+}
+
+func (q *StringQuery) plan() (stringPlan, error) {
+	if !q.some {
+		switch q.criteria {
+		case MatchAlways:
+			return stringPlan{fieldPlan: fieldPlan[string]{none: true, some: true}}, nil
+		case MatchNone, MatchExact, MatchLike, MatchGlob, MatchEditDistance, MatchJaroWinkler, MatchTrigram:
+			return stringPlan{fieldPlan: fieldPlan[string]{none: true}}, nil
+		case MatchAny:
+			return stringPlan{fieldPlan: fieldPlan[string]{some: true}}, nil
+		}
+		return stringPlan{}, fmt.Errorf("QueryError: unsupported matching strategy: %d", q.criteria)
+	}
+
+	switch q.criteria {
+	case MatchAlways:
+		return stringPlan{fieldPlan: fieldPlan[string]{none: true, some: true}}, nil
+	case MatchNone:
+		return stringPlan{fieldPlan: fieldPlan[string]{none: true}}, nil
+	case MatchAny:
+		return stringPlan{fieldPlan: fieldPlan[string]{some: true}}, nil
+	case MatchExact:
+		return stringPlan{fieldPlan: fieldPlan[string]{compare: true, value: q.test}, normalization: q.normalization}, nil
+	case MatchLike, MatchGlob:
+		pattern := q.pattern
+		if pattern == nil {
+			var err error
+			if pattern, err = q.compile(); err != nil {
+				return stringPlan{}, err
+			}
+		}
+		return stringPlan{fieldPlan: fieldPlan[string]{compare: true}, pattern: pattern, normalization: q.normalization}, nil
+	case MatchEditDistance, MatchJaroWinkler, MatchTrigram:
+		if err := q.checkFuzzy(); err != nil {
+			return stringPlan{}, err
+		}
+		return stringPlan{fieldPlan: fieldPlan[string]{compare: true}, fuzzy: q, normalization: q.normalization}, nil
+	}
+	return stringPlan{}, fmt.Errorf("QueryError: unsupported matching strategy: %d", q.criteria)
+}
+
+func (p *stringPlan) match(value string) bool {
+	if !p.compare {
+		return p.some
+	}
+	value = p.normalization.apply(value)
+	if p.pattern != nil {
+		return p.pattern.MatchString(value)
+	} else if p.fuzzy != nil {
+		// The settings were checked when the plan was made
+		matches, _ := p.fuzzy.matchFuzzy(value)
+		return matches
+	}
+	return value == p.value
+}
+
+func (p *stringPlan) matchOption(value optional.Optional[string]) bool {
+	if value.IsNone() {
+		return p.none
+	}
+	return p.match(value.UnsafeUnwrap())
+}
+
+func (q *StringQuery) MatchBatch(values []string, out []bool) error {
+	p, err := q.plan()
+	if err != nil {
+		return err
+	}
+	for i, v := range values {
+		out[i] = p.match(v)
+	}
+	return nil
+}
+
+func (q *StringQuery) MatchOptionBatch(values []optional.Optional[string], out []bool) error {
+	p, err := q.plan()
+	if err != nil {
+		return err
+	}
+	for i, v := range values {
+		out[i] = p.matchOption(v)
+	}
+	return nil
+}
+
+func (q *StringQuery) MatchBitmap(values []string, out Bitmap) error {
+	p, err := q.plan()
+	if err != nil {
+		return err
+	}
+	if !p.compare {
+		if p.some {
+			out.fill(len(values))
+		} else {
+			clear(out)
+		}
+		return nil
+	}
+	clear(out)
+	for i, v := range values {
+		if p.match(v) {
+			out[i/64] |= 1 << (uint(i) % 64)
+		}
+	}
+	return nil
+}
+
+func (q *StringQuery) MatchOptionBitmap(values []optional.Optional[string], out Bitmap) error {
+	p, err := q.plan()
+	if err != nil {
+		return err
+	}
+	clear(out)
+	for i, v := range values {
+		if p.matchOption(v) {
+			out[i/64] |= 1 << (uint(i) % 64)
+		}
+	}
+	return nil
+}
+
+// batchBools runs a bitmap evaluation and unpacks the result, for queries which work on bitmaps internally.
+func batchBools(n int, out []bool, eval func(Bitmap) error) error {
+	bm := NewBitmap(n)
+	if err := eval(bm); err != nil {
+		return err
+	}
+	bm.toBools(out[:n])
+	return nil
+}
+
+// combineBitmaps evaluates each child into a scratch bitmap and combines it with out, which starts with every one of the
+// n values matching for And and none of them for Or. It stops early once the result can't change.
+func combineBitmaps[T comparable](children []Query[T], n int, out Bitmap, and bool, eval func(Query[T], Bitmap) error) error {
+	if and {
+		out.fill(n)
+	} else {
+		clear(out)
+	}
+	if len(children) == 0 {
+		return nil
+	}
+
+	scratch := NewBitmap(n)
+	for _, child := range children {
+		if err := eval(child, scratch); err != nil {
+			return err
+		}
+		if and {
+			out.And(scratch)
+			if out.Count() == 0 {
+				return nil
+			}
+		} else {
+			out.Or(scratch)
+			if out.Count() == n {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// invert flips the first n bits, which is Not on a bitmap of n results.
+func (b Bitmap) invert(n int) {
+	for i := range (n + 63) / 64 {
+		b[i] = ^b[i]
+	}
+	if n%64 != 0 {
+		b[n/64] &= 1<<(uint(n)%64) - 1
+	}
+}
+
+func (q *AndQuery[T]) MatchBatch(values []T, out []bool) error {
+	return batchBools(len(values), out, func(bm Bitmap) error { return q.MatchBitmap(values, bm) })
+}
+
+func (q *AndQuery[T]) MatchOptionBatch(values []optional.Optional[T], out []bool) error {
+	return batchBools(len(values), out, func(bm Bitmap) error { return q.MatchOptionBitmap(values, bm) })
+}
+
+func (q *AndQuery[T]) MatchBitmap(values []T, out Bitmap) error {
+	return combineBitmaps(q.children, len(values), out, true, func(child Query[T], bm Bitmap) error {
+		return EvaluateBitmap(child, values, bm)
+	})
+}
+
+func (q *AndQuery[T]) MatchOptionBitmap(values []optional.Optional[T], out Bitmap) error {
+	return combineBitmaps(q.children, len(values), out, true, func(child Query[T], bm Bitmap) error {
+		return EvaluateOptionBitmap(child, values, bm)
+	})
+}
+
+func (q *OrQuery[T]) MatchBatch(values []T, out []bool) error {
+	return batchBools(len(values), out, func(bm Bitmap) error { return q.MatchBitmap(values, bm) })
+}
+
+func (q *OrQuery[T]) MatchOptionBatch(values []optional.Optional[T], out []bool) error {
+	return batchBools(len(values), out, func(bm Bitmap) error { return q.MatchOptionBitmap(values, bm) })
+}
+
+func (q *OrQuery[T]) MatchBitmap(values []T, out Bitmap) error {
+	return combineBitmaps(q.children, len(values), out, false, func(child Query[T], bm Bitmap) error {
+		return EvaluateBitmap(child, values, bm)
+	})
+}
+
+func (q *OrQuery[T]) MatchOptionBitmap(values []optional.Optional[T], out Bitmap) error {
+	return combineBitmaps(q.children, len(values), out, false, func(child Query[T], bm Bitmap) error {
+		return EvaluateOptionBitmap(child, values, bm)
+	})
+}
+
+func (q *NotQuery[T]) MatchBatch(values []T, out []bool) error {
+	return batchBools(len(values), out, func(bm Bitmap) error { return q.MatchBitmap(values, bm) })
+}
+
+func (q *NotQuery[T]) MatchOptionBatch(values []optional.Optional[T], out []bool) error {
+	return batchBools(len(values), out, func(bm Bitmap) error { return q.MatchOptionBitmap(values, bm) })
+}
+
+func (q *NotQuery[T]) MatchBitmap(values []T, out Bitmap) error {
+	if err := EvaluateBitmap(q.child, values, out); err != nil {
+		return err
+	}
+	out.invert(len(values))
+	return nil
+}
+
+func (q *NotQuery[T]) MatchOptionBitmap(values []optional.Optional[T], out Bitmap) error {
+	if err := EvaluateOptionBitmap(q.child, values, out); err != nil {
+		return err
+	}
+	out.invert(len(values))
+	return nil
+}
+
+// The batch methods of FieldPredicate extract the field from every record and hand the whole batch of fields to the
+// field's query.
+
+func (p *FieldPredicate[T, F]) MatchBatch(records []T, out []bool) error {
+	return batchBools(len(records), out, func(bm Bitmap) error { return p.MatchBitmap(records, bm) })
+}
+
+func (p *FieldPredicate[T, F]) MatchOptionBatch(records []optional.Optional[T], out []bool) error {
+	return batchBools(len(records), out, func(bm Bitmap) error { return p.MatchOptionBitmap(records, bm) })
+}
+
+func (p *FieldPredicate[T, F]) MatchBitmap(records []T, out Bitmap) error {
+	if p.field.option != nil {
+		fields := make([]optional.Optional[F], len(records))
+		for i, r := range records {
+			fields[i] = p.field.option(r)
+		}
+		return EvaluateOptionBitmap(p.query, fields, out)
+	}
+	fields := make([]F, len(records))
+	for i, r := range records {
+		fields[i] = p.field.value(r)
+	}
+	return EvaluateBitmap(p.query, fields, out)
+}
+
+func (p *FieldPredicate[T, F]) MatchOptionBitmap(records []optional.Optional[T], out Bitmap) error {
+	// None records never match, so only the others are evaluated
+	var present []T
+	var indices []int
+	for i, r := range records {
+		if !r.IsNone() {
+			present = append(present, r.UnsafeUnwrap())
+			indices = append(indices, i)
+		}
+	}
+	matched := NewBitmap(len(present))
+	if err := p.MatchBitmap(present, matched); err != nil {
+		return err
+	}
+	clear(out)
+	for j, i := range indices {
+		out.put(i, matched.Has(j))
+	}
+	return nil
+}
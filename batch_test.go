@@ -0,0 +1,241 @@
+package query_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+// assertBatchMatches checks that every batch evaluation agrees with matching the values one at a time.
+func assertBatchMatches[T comparable](t *testing.T, q smartquery.Query[T], values []T) {
+	t.Helper()
+	options := make([]optional.Optional[T], 0, 2*len(values))
+	for _, v := range values {
+		options = append(options, optional.NewOption(v).AsRef(), optional.None[T]().AsRef())
+	}
+
+	out := make([]bool, len(values))
+	assert.NilError(t, smartquery.EvaluateBatch(q, values, out))
+	bm := smartquery.NewBitmap(len(values))
+	assert.NilError(t, smartquery.EvaluateBitmap(q, values, bm))
+	for i, v := range values {
+		expected, err := q.Matches(v)
+		assert.NilError(t, err)
+		assert.Equal(t, out[i], expected, "value %d: %v", i, v)
+		assert.Equal(t, bm.Has(i), expected, "value %d: %v", i, v)
+	}
+	assert.Equal(t, len(bm.Indices()), countTrue(out))
+
+	out = make([]bool, len(options))
+	assert.NilError(t, smartquery.EvaluateOptionBatch(q, options, out))
+	bm = smartquery.NewBitmap(len(options))
+	assert.NilError(t, smartquery.EvaluateOptionBitmap(q, options, bm))
+	for i, v := range options {
+		expected, err := q.MatchesOption(v)
+		assert.NilError(t, err)
+		assert.Equal(t, out[i], expected, "option %d", i)
+		assert.Equal(t, bm.Has(i), expected, "option %d", i)
+	}
+	assert.Equal(t, len(bm.Indices()), countTrue(out))
+}
+
+func countTrue(bools []bool) int {
+	count := 0
+	for _, b := range bools {
+		if b {
+			count++
+		}
+	}
+	return count
+}
+
+func TestFieldQueryBatch(t *testing.T) {
+	values := make([]int, 100)
+	for i := range values {
+		values[i] = i % 7
+	}
+	queries := map[string]smartquery.FieldQuery[int]{
+		"always":     smartquery.Always[int](),
+		"none":       smartquery.None(3),
+		"any":        smartquery.Any(3),
+		"exact":      smartquery.Exact(3),
+		"exact none": smartquery.NewQuery[int](smartquery.MatchExact, optional.None[int]().AsRef()),
+	}
+	for name, q := range queries {
+		t.Run(name, func(t *testing.T) {
+			assertBatchMatches[int](t, q.AsRef(), values)
+		})
+	}
+
+	err := smartquery.EvaluateBatch[int](smartquery.Like(3).AsRef(), values, make([]bool, len(values)))
+	assert.ErrorContains(t, err, "MatchLike")
+}
+
+func TestStringQueryBatch(t *testing.T) {
+	var values []string
+	for i := range 70 {
+		values = append(values, fmt.Sprintf("user%d@example.com", i), "Ünïcode")
+	}
+	glob, err := smartquery.GlobString("user?@*")
+	assert.NilError(t, err)
+	queries := map[string]smartquery.StringQuery{
+		"always":     smartquery.AlwaysString(),
+		"none":       smartquery.NoneString("x"),
+		"any":        smartquery.AnyString("x"),
+		"exact":      smartquery.ExactString("user1@example.com"),
+		"like":       smartquery.LikeString("user1%"),
+		"glob":       glob,
+		"normalized": smartquery.ExactString("unicode").WithNormalization(smartquery.NormalizeCase | smartquery.NormalizeAccents),
+		"like none":  smartquery.NewStringQuery(smartquery.MatchLike, optional.None[string]().AsRef()),
+		"edits":      smartquery.WithinEdits("user1@example.org", 3),
+		"jaro":       smartquery.JaroWinkler("UNICODE", 0.8).WithNormalization(smartquery.NormalizeCase | smartquery.NormalizeAccents),
+		"trigram":    smartquery.Trigram("user12", 0.3),
+	}
+	for name, q := range queries {
+		t.Run(name, func(t *testing.T) {
+			assertBatchMatches[string](t, q.AsRef(), values)
+		})
+	}
+}
+
+func TestCombinatorBatch(t *testing.T) {
+	var records []testStruct
+	for i := range 150 {
+		stars := optional.NewOption(i % 5).AsRef()
+		if i%3 == 0 {
+			stars = optional.None[int]().AsRef()
+		}
+		records = append(records, testStruct{
+			Name:    fmt.Sprintf("user%d", i),
+			Email:   optional.None[string]().AsRef(),
+			Balance: i % 10,
+			Stars:   stars,
+		})
+	}
+
+	queries := map[string]smartquery.Query[testStruct]{
+		"and": smartquery.And[testStruct](
+			balanceField.Where(smartquery.Exact(4).AsRef()).AsRef(),
+			nameField.Where(smartquery.LikeString("user1%").AsRef()).AsRef(),
+		).AsRef(),
+		"or": smartquery.Or[testStruct](
+			starsField.Where(smartquery.Exact(2).AsRef()).AsRef(),
+			starsField.Where(smartquery.NewQuery[int](smartquery.MatchNone, optional.None[int]().AsRef()).AsRef()).AsRef(),
+		).AsRef(),
+		"not": smartquery.Not[testStruct](balanceField.Where(smartquery.Exact(4).AsRef()).AsRef()).AsRef(),
+		"nested": smartquery.And[testStruct](
+			smartquery.Not[testStruct](smartquery.Or[testStruct](
+				balanceField.Where(smartquery.Exact(1).AsRef()).AsRef(),
+				balanceField.Where(smartquery.Exact(2).AsRef()).AsRef(),
+			).AsRef()).AsRef(),
+			// Not a BatchQuery, so it is evaluated one record at a time
+			smartquery.Predicate("even", func(s testStruct) (bool, error) { return s.Balance%2 == 0, nil }).AsRef(),
+		).AsRef(),
+		"empty and": smartquery.And[testStruct]().AsRef(),
+		"empty or":  smartquery.Or[testStruct]().AsRef(),
+	}
+	for name, q := range queries {
+		t.Run(name, func(t *testing.T) {
+			assertBatchMatches(t, q, records)
+		})
+	}
+}
+
+func TestBatchResultSize(t *testing.T) {
+	q := smartquery.Exact(1)
+	err := smartquery.EvaluateBatch[int](q.AsRef(), []int{1, 2, 3}, make([]bool, 2))
+	assert.ErrorContains(t, err, "batch of 3 values only has room for 2 results")
+	err = smartquery.EvaluateBitmap[int](q.AsRef(), make([]int, 65), smartquery.NewBitmap(64))
+	assert.ErrorContains(t, err, "batch of 65 values only has room for 64 results")
+
+	// Bits past the end of the batch are cleared
+	bm := smartquery.NewBitmap(128)
+	bm.Set(100)
+	assert.NilError(t, smartquery.EvaluateBitmap[int](smartquery.Not[int](q.AsRef()).AsRef(), []int{1, 2}, bm))
+	assert.DeepEqual(t, bm.Indices(), []int{1})
+}
+
+func benchmarkValues() []string {
+	values := make([]string, 4096)
+	for i := range values {
+		values[i] = fmt.Sprintf("user%d@example.com", i)
+	}
+	return values
+}
+
+func BenchmarkStringQueryMatches(b *testing.B) {
+	q := smartquery.ExactString("user42@example.com").AsRef()
+	values := benchmarkValues()
+	b.ResetTimer()
+	for range b.N {
+		for _, v := range values {
+			if _, err := q.Matches(v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkStringQueryMatchBatch(b *testing.B) {
+	q := smartquery.ExactString("user42@example.com").AsRef()
+	values := benchmarkValues()
+	out := make([]bool, len(values))
+	b.ResetTimer()
+	for range b.N {
+		if err := q.MatchBatch(values, out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFieldQueryMatchesOption(b *testing.B) {
+	q := smartquery.Exact(42).AsRef()
+	values := make([]optional.Optional[int], 4096)
+	for i := range values {
+		values[i] = optional.NewOption(i).AsRef()
+	}
+	b.ResetTimer()
+	for range b.N {
+		for _, v := range values {
+			if _, err := q.MatchesOption(v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkFieldQueryMatchOptionBitmap(b *testing.B) {
+	q := smartquery.Exact(42).AsRef()
+	values := make([]optional.Optional[int], 4096)
+	for i := range values {
+		values[i] = optional.NewOption(i).AsRef()
+	}
+	out := smartquery.NewBitmap(len(values))
+	b.ResetTimer()
+	for range b.N {
+		if err := q.MatchOptionBitmap(values, out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAndBitmap(b *testing.B) {
+	records := make([]testStruct, 4096)
+	for i := range records {
+		records[i] = testStruct{Name: fmt.Sprintf("user%d", i), Email: optional.None[string]().AsRef(), Balance: i % 100, Stars: optional.NewOption(i % 5).AsRef()}
+	}
+	q := smartquery.And[testStruct](
+		balanceField.Where(smartquery.Exact(42).AsRef()).AsRef(),
+		starsField.Where(smartquery.Exact(2).AsRef()).AsRef(),
+	).AsRef()
+	out := smartquery.NewBitmap(len(records))
+	b.ResetTimer()
+	for range b.N {
+		if err := q.MatchBitmap(records, out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
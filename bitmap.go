@@ -0,0 +1,93 @@
+package query
+
+import (
+	"math/bits"
+)
+
+// Bitmap is a dense set of non-negative integers, typically record ids or row numbers. The zero value is an empty set
+// and the bitmap grows as needed when bits are set.
+type Bitmap []uint64
+
+func NewBitmap(size int) Bitmap {
+	return make(Bitmap, (size+63)/64)
+}
+
+// Set adds i to the set. A Bitmap can't hold negative numbers, so setting a negative i does nothing.
+func (b *Bitmap) Set(i int) {
+	if i < 0 {
+		return
+	}
+	word := i / 64
+	if word >= len(*b) {
+		grown := make(Bitmap, word+1, max(word+1, 2*len(*b)))
+		copy(grown, *b)
+		*b = grown
+	}
+	(*b)[word] |= 1 << (uint(i) % 64)
+}
+
+// Clear removes i from the set. Clearing a negative i does nothing.
+func (b Bitmap) Clear(i int) {
+	word := i / 64
+	if i >= 0 && word < len(b) {
+		b[word] &^= 1 << (uint(i) % 64)
+	}
+}
+
+func (b Bitmap) Has(i int) bool {
+	word := i / 64
+	if i < 0 || word >= len(b) {
+		return false
+	}
+	return b[word]&(1<<(uint(i)%64)) != 0
+}
+
+// Count returns the number of bits which are set.
+func (b Bitmap) Count() int {
+	count := 0
+	for _, w := range b {
+		count += bits.OnesCount64(w)
+	}
+	return count
+}
+
+// And intersects b with other in place.
+func (b Bitmap) And(other Bitmap) {
+	for i := range b {
+		if i < len(other) {
+			b[i] &= other[i]
+		} else {
+			b[i] = 0
+		}
+	}
+}
+
+// Or unions b with other in place. Only bits within the current size of b are considered.
+func (b Bitmap) Or(other Bitmap) {
+	for i := range b {
+		if i < len(other) {
+			b[i] |= other[i]
+		}
+	}
+}
+
+// AndNot removes every bit set in other from b in place.
+func (b Bitmap) AndNot(other Bitmap) {
+	for i := range b {
+		if i < len(other) {
+			b[i] &^= other[i]
+		}
+	}
+}
+
+// Indices returns the set bits in ascending order.
+func (b Bitmap) Indices() []int {
+	out := make([]int, 0, b.Count())
+	for i, w := range b {
+		for w != 0 {
+			out = append(out, i*64+bits.TrailingZeros64(w))
+			w &= w - 1
+		}
+	}
+	return out
+}
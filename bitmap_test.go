@@ -0,0 +1,51 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestBitmap(t *testing.T) {
+	var b smartquery.Bitmap
+	b.Set(3)
+	b.Set(64)
+	b.Set(200)
+
+	assert.Assert(t, b.Has(3))
+	assert.Assert(t, b.Has(200))
+	assert.Assert(t, !b.Has(4))
+	assert.Assert(t, !b.Has(1000))
+	assert.Assert(t, !b.Has(-1))
+	assert.Equal(t, b.Count(), 3)
+	assert.DeepEqual(t, b.Indices(), []int{3, 64, 200})
+
+	b.Clear(64)
+	assert.DeepEqual(t, b.Indices(), []int{3, 200})
+
+	// Negative numbers can't be in the set, so they don't touch the last bit of the first word
+	b.Set(63)
+	b.Clear(-1)
+	assert.Assert(t, b.Has(63))
+	b.Set(-1)
+	b.Clear(63)
+	assert.Assert(t, !b.Has(-1))
+	assert.DeepEqual(t, b.Indices(), []int{3, 200})
+
+	other := smartquery.NewBitmap(256)
+	other.Set(3)
+	other.Set(100)
+
+	and := append(smartquery.Bitmap{}, b...)
+	and.And(other)
+	assert.DeepEqual(t, and.Indices(), []int{3})
+
+	or := append(smartquery.Bitmap{}, b...)
+	or.Or(other)
+	assert.DeepEqual(t, or.Indices(), []int{3, 100, 200})
+
+	andNot := append(smartquery.Bitmap{}, b...)
+	andNot.AndNot(other)
+	assert.DeepEqual(t, andNot.Indices(), []int{200})
+}
@@ -0,0 +1,198 @@
+package query
+
+import (
+	"slices"
+)
+
+// btreeDegree is the minimum number of children of every node of a btree except the root.
+const btreeDegree = 32
+
+// btree is an ordered set kept in a B-tree, so that inserting and removing are O(log n) however large it gets. Items
+// which compare as equal are the same item.
+type btree[E any] struct {
+	root    *btreeNode[E]
+	compare func(a, b E) int
+}
+
+type btreeNode[E any] struct {
+	items    []E
+	children []*btreeNode[E] // nil for leaves
+}
+
+func newBtree[E any](compare func(a, b E) int) *btree[E] {
+	return &btree[E]{compare: compare}
+}
+
+const (
+	btreeMaxItems = 2*btreeDegree - 1
+	btreeMinItems = btreeDegree - 1
+)
+
+// insert adds item to the tree and returns false if it was already there.
+func (t *btree[E]) insert(item E) bool {
+	if t.root == nil {
+		t.root = &btreeNode[E]{items: []E{item}}
+		return true
+	}
+	if len(t.root.items) >= btreeMaxItems {
+		mid, right := t.root.split(btreeMaxItems / 2)
+		t.root = &btreeNode[E]{items: []E{mid}, children: []*btreeNode[E]{t.root, right}}
+	}
+	return t.root.insert(item, t.compare)
+}
+
+// remove takes item out of the tree and returns false if it wasn't there.
+func (t *btree[E]) remove(item E) bool {
+	if t.root == nil {
+		return false
+	}
+	removed := t.root.remove(item, t.compare)
+	if len(t.root.items) == 0 {
+		if t.root.children == nil {
+			t.root = nil
+		} else {
+			t.root = t.root.children[0]
+		}
+	}
+	return removed
+}
+
+// ascend calls fn for every item from the first which is not less than from, in order, until fn returns false. A nil
+// from starts at the smallest item.
+func (t *btree[E]) ascend(from *E, fn func(E) bool) {
+	if t.root != nil {
+		t.root.ascend(from, t.compare, fn)
+	}
+}
+
+// split moves the items after i (and their children) into a new node and returns the item at i, which belongs in the
+// parent between the two nodes.
+func (n *btreeNode[E]) split(i int) (E, *btreeNode[E]) {
+	mid := n.items[i]
+	right := &btreeNode[E]{items: slices.Clone(n.items[i+1:])}
+	clear(n.items[i:])
+	n.items = n.items[:i]
+	if n.children != nil {
+		right.children = slices.Clone(n.children[i+1:])
+		clear(n.children[i+1:])
+		n.children = n.children[:i+1]
+	}
+	return mid, right
+}
+
+// insert adds item below a node which is not full, splitting full children on the way down.
+func (n *btreeNode[E]) insert(item E, compare func(a, b E) int) bool {
+	i, found := slices.BinarySearchFunc(n.items, item, compare)
+	if found {
+		return false
+	}
+	if n.children == nil {
+		n.items = slices.Insert(n.items, i, item)
+		return true
+	}
+	if len(n.children[i].items) >= btreeMaxItems {
+		mid, right := n.children[i].split(btreeMaxItems / 2)
+		n.items = slices.Insert(n.items, i, mid)
+		n.children = slices.Insert(n.children, i+1, right)
+		switch c := compare(item, mid); {
+		case c == 0:
+			return false
+		case c > 0:
+			i++
+		}
+	}
+	return n.children[i].insert(item, compare)
+}
+
+// remove takes item out from below a node, making sure every child it descends into has an item to spare first.
+func (n *btreeNode[E]) remove(item E, compare func(a, b E) int) bool {
+	i, found := slices.BinarySearchFunc(n.items, item, compare)
+	if n.children == nil {
+		if !found {
+			return false
+		}
+		n.items = slices.Delete(n.items, i, i+1)
+		return true
+	}
+	if len(n.children[i].items) <= btreeMinItems {
+		n.grow(i)
+		return n.remove(item, compare)
+	}
+	if found {
+		// Replace the item with the largest item before it, which is always in a leaf
+		n.items[i] = n.children[i].removeMax()
+		return true
+	}
+	return n.children[i].remove(item, compare)
+}
+
+func (n *btreeNode[E]) removeMax() E {
+	if n.children == nil {
+		last := n.items[len(n.items)-1]
+		n.items = slices.Delete(n.items, len(n.items)-1, len(n.items))
+		return last
+	}
+	i := len(n.items)
+	if len(n.children[i].items) <= btreeMinItems {
+		n.grow(i)
+		return n.removeMax()
+	}
+	return n.children[i].removeMax()
+}
+
+// grow gives child i an extra item, either by moving one over from a sibling through the parent or by merging it with
+// a sibling.
+func (n *btreeNode[E]) grow(i int) {
+	child := n.children[i]
+	if i > 0 && len(n.children[i-1].items) > btreeMinItems {
+		left := n.children[i-1]
+		child.items = slices.Insert(child.items, 0, n.items[i-1])
+		n.items[i-1] = left.items[len(left.items)-1]
+		left.items = slices.Delete(left.items, len(left.items)-1, len(left.items))
+		if left.children != nil {
+			child.children = slices.Insert(child.children, 0, left.children[len(left.children)-1])
+			left.children = slices.Delete(left.children, len(left.children)-1, len(left.children))
+		}
+		return
+	}
+	if i < len(n.items) && len(n.children[i+1].items) > btreeMinItems {
+		right := n.children[i+1]
+		child.items = append(child.items, n.items[i])
+		n.items[i] = right.items[0]
+		right.items = slices.Delete(right.items, 0, 1)
+		if right.children != nil {
+			child.children = append(child.children, right.children[0])
+			right.children = slices.Delete(right.children, 0, 1)
+		}
+		return
+	}
+
+	if i == len(n.items) {
+		i--
+		child = n.children[i]
+	}
+	merged := n.children[i+1]
+	child.items = append(child.items, n.items[i])
+	child.items = append(child.items, merged.items...)
+	child.children = append(child.children, merged.children...)
+	n.items = slices.Delete(n.items, i, i+1)
+	n.children = slices.Delete(n.children, i+1, i+2)
+}
+
+func (n *btreeNode[E]) ascend(from *E, compare func(a, b E) int, fn func(E) bool) bool {
+	i := 0
+	if from != nil {
+		i, _ = slices.BinarySearchFunc(n.items, *from, compare)
+	}
+	for ; i <= len(n.items); i++ {
+		if n.children != nil && !n.children[i].ascend(from, compare, fn) {
+			return false
+		}
+		if i < len(n.items) && !fn(n.items[i]) {
+			return false
+		}
+		// Everything after the first child visited comes after from
+		from = nil
+	}
+	return true
+}
@@ -0,0 +1,26 @@
+package query
+
+import (
+	"time"
+)
+
+// Clock provides the current time to queries which are relative to now, such as Within. Swap in a FixedClock to make
+// tests deterministic.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to the Clock interface.
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the default clock, which reads the system time.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// FixedClock returns a Clock which always returns the given time.
+func FixedClock(now time.Time) Clock {
+	return ClockFunc(func() time.Time { return now })
+}
@@ -0,0 +1,230 @@
+package query
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Collection is an in-memory set of records which keeps secondary indexes on some of their fields. Find uses the
+// indexes to narrow down the candidate records for predicates on indexed fields (see Field.Where) that appear in the
+// top level And-tree of a query, and evaluates whatever is left of the query only against those candidates. Queries
+// with nothing the indexes can answer are evaluated against every record, the same as Filter.
+//
+// Records are identified by an id which is handed out by Insert. Ids of deleted records are reused.
+type Collection[T comparable] struct {
+	lock    sync.RWMutex
+	records []T
+	live    Bitmap
+	free    []int
+	size    int
+	indexes map[string]Index[T]
+}
+
+// NewCollection creates an empty Collection with the given indexes. Each index must be on a differently named field;
+// if two indexes share a field name only the last one is used to answer queries.
+func NewCollection[T comparable](indexes ...Index[T]) *Collection[T] {
+	c := &Collection[T]{indexes: make(map[string]Index[T], len(indexes))}
+	for _, idx := range indexes {
+		c.indexes[idx.Field()] = idx
+	}
+	return c
+}
+
+func (c *Collection[T]) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.size
+}
+
+func (c *Collection[T]) Insert(record T) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var id int
+	if n := len(c.free); n > 0 {
+		id = c.free[n-1]
+		c.free = c.free[:n-1]
+		c.records[id] = record
+	} else {
+		id = len(c.records)
+		c.records = append(c.records, record)
+	}
+	c.live.Set(id)
+	c.size++
+	for _, idx := range c.indexes {
+		idx.insert(id, record)
+	}
+	return id
+}
+
+func (c *Collection[T]) Get(id int) (T, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if !c.live.Has(id) {
+		var zero T
+		return zero, false
+	}
+	return c.records[id], true
+}
+
+func (c *Collection[T]) Update(id int, record T) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.live.Has(id) {
+		return fmt.Errorf("CollectionError: no record with id %d", id)
+	}
+	old := c.records[id]
+	for _, idx := range c.indexes {
+		idx.remove(id, old)
+		idx.insert(id, record)
+	}
+	c.records[id] = record
+	return nil
+}
+
+func (c *Collection[T]) Delete(id int) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.live.Has(id) {
+		return fmt.Errorf("CollectionError: no record with id %d", id)
+	}
+	for _, idx := range c.indexes {
+		idx.remove(id, c.records[id])
+	}
+	var zero T
+	c.records[id] = zero
+	c.live.Clear(id)
+	c.free = append(c.free, id)
+	c.size--
+	return nil
+}
+
+// Find returns every record matching the query in id order.
+func (c *Collection[T]) Find(query Query[T]) ([]T, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	ids, err := c.find(nil, query)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]T, len(ids))
+	for i, id := range ids {
+		out[i] = c.records[id]
+	}
+	return out, nil
+}
+
+// FindIDs returns the ids of every record matching the query in ascending order.
+func (c *Collection[T]) FindIDs(query Query[T]) ([]int, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.find(nil, query)
+}
+
+// FindContext is Find with the query evaluated in the given context. Indexes compare keys exactly, so they are not used
+// when the context has a collation.
+func (c *Collection[T]) FindContext(ctx *EvalContext, query Query[T]) ([]T, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	ids, err := c.find(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]T, len(ids))
+	for i, id := range ids {
+		out[i] = c.records[id]
+	}
+	return out, nil
+}
+
+func (c *Collection[T]) find(ctx *EvalContext, query Query[T]) ([]int, error) {
+	var candidates []int
+	var indexed bool
+	residual := query
+	if ctx.Collation() == nil {
+		candidates, residual, indexed = c.plan(query)
+	}
+	if !indexed {
+		candidates = c.live.Indices()
+	}
+	if residual == nil {
+		// candidates may belong to an index, so never hand it out directly
+		return slices.Clone(candidates), nil
+	}
+
+	out := make([]int, 0, len(candidates))
+	for _, id := range candidates {
+		matched, err := Evaluate(ctx, residual, c.records[id])
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+// plan splits the top level And-tree of a query into the predicates that can be answered by an index and the residual
+// query which has to be evaluated per record. candidates is only meaningful if indexed is true, and residual is nil if
+// the indexes answered the whole query.
+//
+// Note that only the candidate records are evaluated against the residual query, so an error that a full scan would
+// have returned for some record outside of the candidates is not seen.
+func (c *Collection[T]) plan(query Query[T]) (candidates []int, residual Query[T], indexed bool) {
+	var rest []Query[T]
+	for _, conjunct := range flattenAnd(query) {
+		ids, ok := c.lookup(conjunct)
+		if !ok {
+			rest = append(rest, conjunct)
+			continue
+		}
+		if !indexed {
+			candidates = ids
+			indexed = true
+		} else {
+			candidates = intersectIDs(candidates, ids)
+		}
+	}
+
+	if len(rest) == 1 {
+		residual = rest[0]
+	} else if len(rest) > 1 {
+		residual = And(rest...).AsRef()
+	}
+	return candidates, residual, indexed
+}
+
+func (c *Collection[T]) lookup(query Query[T]) ([]int, bool) {
+	p, ok := query.(fieldPredicate)
+	if !ok {
+		return nil, false
+	}
+	idx, ok := c.indexes[p.fieldName()]
+	if !ok {
+		return nil, false
+	}
+	return idx.lookup(p.fieldQuery())
+}
+
+// flattenAnd returns the conjuncts of a (possibly nested) And-tree. Anything other than an AndQuery is a single
+// conjunct.
+func flattenAnd[T comparable](query Query[T]) []Query[T] {
+	and, ok := query.(*AndQuery[T])
+	if !ok {
+		return []Query[T]{query}
+	}
+	var out []Query[T]
+	for _, child := range and.children {
+		out = append(out, flattenAnd(child)...)
+	}
+	return out
+}
@@ -0,0 +1,211 @@
+package query_test
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+// countingQuery wraps another query and counts how many times it was evaluated so tests can tell whether the
+// collection used an index or scanned.
+type countingQuery[T comparable] struct {
+	query smartquery.Query[T]
+	calls int
+}
+
+func (q *countingQuery[T]) Matches(value T) (bool, error) {
+	q.calls++
+	return q.query.Matches(value)
+}
+
+func (q *countingQuery[T]) MatchesOption(value optional.Optional[T]) (bool, error) {
+	q.calls++
+	return q.query.MatchesOption(value)
+}
+
+func testCollection() *smartquery.Collection[testStruct] {
+	c := smartquery.NewCollection(
+		smartquery.HashIndex(nameField),
+		smartquery.HashIndex(emailField),
+		smartquery.SortedIndex(balanceField),
+		smartquery.SortedIndex(starsField),
+	)
+	for i := 0; i < 100; i++ {
+		s := testStruct{
+			Name:    fmt.Sprintf("tester-%d", i%10),
+			Email:   optional.None[string]().AsRef(),
+			Balance: i,
+			Stars:   optional.None[int]().AsRef(),
+		}
+		if i%2 == 0 {
+			s.Email = optional.NewOption(fmt.Sprintf("tester%d@testing.org", i)).AsRef()
+		}
+		if i%3 == 0 {
+			s.Stars = optional.NewOption(i % 5).AsRef()
+		}
+		c.Insert(s)
+	}
+	return c
+}
+
+func TestCollectionIndexedFind(t *testing.T) {
+	c := testCollection()
+	assert.Equal(t, c.Len(), 100)
+
+	all := make([]testStruct, 0, 100)
+	for i := 0; i < 100; i++ {
+		s, ok := c.Get(i)
+		assert.Assert(t, ok)
+		all = append(all, s)
+	}
+
+	queries := map[string]smartquery.Query[testStruct]{
+		"exact":        nameField.Where(smartquery.ExactString("tester-3").AsRef()).AsRef(),
+		"field exact":  balanceField.Where(smartquery.Exact(42).AsRef()).AsRef(),
+		"in":           nameField.Where(smartquery.In("tester-1", "tester-2", "nobody").AsRef()).AsRef(),
+		"range":        balanceField.Where(smartquery.Between(10, 20).AsRef()).AsRef(),
+		"none":         emailField.Where(smartquery.NoneString("").AsRef()).AsRef(),
+		"option none":  starsField.Where(smartquery.None(0).AsRef()).AsRef(),
+		"option range": starsField.Where(smartquery.AtLeast(3).AsRef()).AsRef(),
+		"and": smartquery.And[testStruct](
+			nameField.Where(smartquery.ExactString("tester-4").AsRef()).AsRef(),
+			balanceField.Where(smartquery.LessThan(50).AsRef()).AsRef(),
+			emailField.Where(smartquery.LikeString("%@testing.org").AsRef()).AsRef(),
+		).AsRef(),
+		"nested and": smartquery.And[testStruct](
+			smartquery.And[testStruct](balanceField.Where(smartquery.GreaterThan(90).AsRef()).AsRef()).AsRef(),
+			smartquery.Not[testStruct](nameField.Where(smartquery.ExactString("tester-5").AsRef()).AsRef()).AsRef(),
+		).AsRef(),
+		"scan": smartquery.Or[testStruct](
+			nameField.Where(smartquery.ExactString("tester-1").AsRef()).AsRef(),
+			balanceField.Where(smartquery.Exact(2).AsRef()).AsRef(),
+		).AsRef(),
+	}
+
+	for name, q := range queries {
+		expected, err := smartquery.Filter(all, q)
+		assert.NilError(t, err)
+
+		found, err := c.Find(q)
+		assert.NilError(t, err)
+		assert.Assert(t, len(found) > 0, "%s: test query should match something", name)
+		assert.Equal(t, len(found), len(expected), "%s: collection and filter disagree", name)
+		for i := range found {
+			assert.Assert(t, found[i] == expected[i], "%s: collection and filter disagree", name)
+		}
+	}
+}
+
+func TestCollectionUsesIndexes(t *testing.T) {
+	c := testCollection()
+
+	residual := &countingQuery[testStruct]{query: smartquery.Always[testStruct]().AsRef()}
+	q := smartquery.And[testStruct](nameField.Where(smartquery.ExactString("tester-7").AsRef()).AsRef(), residual)
+	ids, err := c.FindIDs(q.AsRef())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ids, []int{7, 17, 27, 37, 47, 57, 67, 77, 87, 97})
+	assert.Equal(t, residual.calls, 10, "residual query should only be evaluated for the indexed candidates")
+
+	scan := &countingQuery[testStruct]{query: nameField.Where(smartquery.ExactString("tester-7").AsRef()).AsRef()}
+	ids, err = c.FindIDs(scan)
+	assert.NilError(t, err)
+	assert.Equal(t, len(ids), 10)
+	assert.Equal(t, scan.calls, 100, "queries without indexed predicates should scan every record")
+}
+
+func TestCollectionMutation(t *testing.T) {
+	c := testCollection()
+	byName := nameField.Where(smartquery.ExactString("renamed").AsRef()).AsRef()
+
+	s, ok := c.Get(5)
+	assert.Assert(t, ok)
+	s.Name = "renamed"
+	assert.NilError(t, c.Update(5, s))
+
+	ids, err := c.FindIDs(byName)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ids, []int{5})
+
+	ids, err = c.FindIDs(nameField.Where(smartquery.ExactString("tester-5").AsRef()).AsRef())
+	assert.NilError(t, err)
+	assert.Equal(t, len(ids), 9, "updated record is still indexed under its old name")
+
+	assert.NilError(t, c.Delete(5))
+	assert.Equal(t, c.Len(), 99)
+	_, ok = c.Get(5)
+	assert.Assert(t, !ok)
+	ids, err = c.FindIDs(byName)
+	assert.NilError(t, err)
+	assert.Equal(t, len(ids), 0)
+
+	assert.ErrorContains(t, c.Delete(5), "CollectionError")
+	assert.ErrorContains(t, c.Update(5, s), "CollectionError")
+
+	// Deleted ids are reused
+	id := c.Insert(s)
+	assert.Equal(t, id, 5)
+	ids, err = c.FindIDs(byName)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ids, []int{5})
+}
+
+func TestCollectionSortedIndexChurn(t *testing.T) {
+	// Enough records for the sorted index to be several levels deep, with plenty of duplicate keys
+	c := smartquery.NewCollection(smartquery.SortedIndex(balanceField))
+	rng := rand.New(rand.NewSource(1))
+	live := make(map[int]testStruct)
+	for i := 0; i < 20000; i++ {
+		s := testStruct{Name: "churn", Email: optional.None[string]().AsRef(), Balance: rng.Intn(5000), Stars: optional.None[int]().AsRef()}
+		live[c.Insert(s)] = s
+	}
+	for id := range live {
+		if rng.Intn(3) == 0 {
+			assert.NilError(t, c.Delete(id))
+			delete(live, id)
+		} else if rng.Intn(2) == 0 {
+			s := live[id]
+			s.Balance = rng.Intn(5000)
+			assert.NilError(t, c.Update(id, s))
+			live[id] = s
+		}
+	}
+
+	queries := []smartquery.Query[int]{
+		smartquery.Between(1000, 1200).AsRef(),
+		smartquery.GreaterThan(4990).AsRef(),
+		smartquery.LessThan(7).AsRef(),
+		smartquery.Exact(2500).AsRef(),
+	}
+	for _, q := range queries {
+		expected := []int{}
+		for id, s := range live {
+			if matched, _ := q.Matches(s.Balance); matched {
+				expected = append(expected, id)
+			}
+		}
+		slices.Sort(expected)
+		ids, err := c.FindIDs(balanceField.Where(q).AsRef())
+		assert.NilError(t, err)
+		assert.DeepEqual(t, ids, expected)
+	}
+}
+
+func BenchmarkSortedIndexInsert(b *testing.B) {
+	records := make([]testStruct, 1_000_000)
+	rng := rand.New(rand.NewSource(1))
+	for i := range records {
+		records[i] = testStruct{Name: "bench", Email: optional.None[string]().AsRef(), Balance: rng.Int(), Stars: optional.None[int]().AsRef()}
+	}
+	b.ResetTimer()
+	for range b.N {
+		c := smartquery.NewCollection(smartquery.SortedIndex(balanceField))
+		for _, r := range records {
+			c.Insert(r)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package query
+
+import (
+	"fmt"
+
+	"github.com/brnsampson/optional"
+)
+
+// Columns is a batch of records stored as one slice per field (struct of arrays) rather than as a slice of records, the
+// way columnar formats like Arrow keep them. A query on the record type can be evaluated against it with MatchColumns
+// without building any records: each Field.Where in the query reads the column with the same name as the field and
+// hands the whole column to the field's query as a batch, so built-in queries like FieldQuery and StringQuery turn
+// into plain column scans. Rows with a value are matched the same way as a plain value, and the result for rows
+// without one is worked out once per column with MatchesOption.
+type Columns struct {
+	rows    int
+	columns map[string]any
+}
+
+type column[F comparable] struct {
+	values []F
+	valid  Bitmap
+}
+
+func NewColumns(rows int) *Columns {
+	return &Columns{rows: rows, columns: make(map[string]any)}
+}
+
+func (c *Columns) Rows() int {
+	return c.rows
+}
+
+// AddColumn adds the values of the named field for every row. valid marks the rows which have a value; the others are
+// None and whatever is in values for them is ignored. A nil valid means every row has a value.
+func AddColumn[F comparable](c *Columns, name string, values []F, valid Bitmap) error {
+	if len(values) != c.rows {
+		return fmt.Errorf("QueryError: column %s has %d rows, not %d", name, len(values), c.rows)
+	}
+	if valid != nil && 64*len(valid) < c.rows {
+		return fmt.Errorf("QueryError: validity bitmap of column %s is too small for %d rows", name, c.rows)
+	}
+	c.columns[name] = column[F]{values, valid}
+	return nil
+}
+
+// MatchColumns evaluates a query against every row of the columns. It overwrites out the same way as
+// BatchQuery.MatchBitmap, so out must have room for c.Rows() bits. Only And, Or, Not, Field.Where and references can
+// be evaluated on columns; anything which needs the whole record, like a predicate function, is an error.
+func MatchColumns[T comparable](query Query[T], c *Columns, out Bitmap) error {
+	if err := checkBatch(c.rows, 64*len(out)); err != nil {
+		return err
+	}
+	return matchColumns(query, c, out)
+}
+
+// SelectColumns returns the rows of the columns which match the query in ascending order: a selection vector.
+func SelectColumns[T comparable](query Query[T], c *Columns) ([]int, error) {
+	out := NewBitmap(c.rows)
+	if err := matchColumns(query, c, out); err != nil {
+		return nil, err
+	}
+	return out.Indices(), nil
+}
+
+// columnar is implemented by queries which can be evaluated on Columns.
+type columnar interface {
+	matchColumns(c *Columns, out Bitmap) error
+}
+
+func matchColumns[T comparable](query Query[T], c *Columns, out Bitmap) error {
+	if q, ok := query.(columnar); ok {
+		return q.matchColumns(c, out)
+	}
+	return fmt.Errorf("QueryError: %T cannot be evaluated on columns", query)
+}
+
+func (q *AndQuery[T]) matchColumns(c *Columns, out Bitmap) error {
+	return combineBitmaps(q.children, c.rows, out, true, func(child Query[T], bm Bitmap) error {
+		return matchColumns(child, c, bm)
+	})
+}
+
+func (q *OrQuery[T]) matchColumns(c *Columns, out Bitmap) error {
+	return combineBitmaps(q.children, c.rows, out, false, func(child Query[T], bm Bitmap) error {
+		return matchColumns(child, c, bm)
+	})
+}
+
+func (q *NotQuery[T]) matchColumns(c *Columns, out Bitmap) error {
+	if err := matchColumns(q.child, c, out); err != nil {
+		return err
+	}
+	out.invert(c.rows)
+	return nil
+}
+
+func (q *RefQuery[T]) matchColumns(c *Columns, out Bitmap) error {
+	target, err := q.Resolve()
+	if err != nil {
+		return err
+	}
+	return matchColumns(target, c, out)
+}
+
+func (p *FieldPredicate[T, F]) matchColumns(c *Columns, out Bitmap) error {
+	found, ok := c.columns[p.field.name]
+	if !ok {
+		return fmt.Errorf("QueryError: no column named %s", p.field.name)
+	}
+	col, ok := found.(column[F])
+	if !ok {
+		var zero F
+		return fmt.Errorf("QueryError: column %s does not hold values of type %T", p.field.name, zero)
+	}
+
+	if err := EvaluateBitmap(p.query, col.values, out); err != nil {
+		return err
+	}
+	if col.valid == nil {
+		return nil
+	}
+
+	// Every row was evaluated as if it had a value. Replace the result for the rows without one with the result for None,
+	// which is the same for all of them.
+	out.And(col.valid)
+	none, err := p.query.MatchesOption(optional.None[F]().AsRef())
+	if err != nil {
+		return err
+	}
+	if none {
+		invalid := NewBitmap(c.rows)
+		copy(invalid, col.valid)
+		invalid.invert(c.rows)
+		out.Or(invalid)
+	}
+	return nil
+}
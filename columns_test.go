@@ -0,0 +1,105 @@
+package query_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+// testColumns builds the same records both as structs and as columns.
+func testColumns(t *testing.T, rows int) ([]testStruct, *smartquery.Columns) {
+	records := make([]testStruct, rows)
+	names := make([]string, rows)
+	emails := make([]string, rows)
+	balances := make([]int, rows)
+	stars := make([]int, rows)
+	validEmails := smartquery.NewBitmap(rows)
+	validStars := smartquery.NewBitmap(rows)
+
+	for i := range rows {
+		names[i] = fmt.Sprintf("user%d", i)
+		balances[i] = i % 10
+		records[i] = testStruct{Name: names[i], Email: optional.None[string]().AsRef(), Balance: balances[i], Stars: optional.None[int]().AsRef()}
+		if i%4 != 0 {
+			emails[i] = fmt.Sprintf("user%d@example.com", i)
+			validEmails.Set(i)
+			records[i].Email = optional.NewOption(emails[i]).AsRef()
+		}
+		if i%3 != 0 {
+			stars[i] = i % 5
+			validStars.Set(i)
+			records[i].Stars = optional.NewOption(stars[i]).AsRef()
+		} else {
+			// Garbage which must be ignored
+			stars[i] = 2
+		}
+	}
+
+	columns := smartquery.NewColumns(rows)
+	assert.NilError(t, smartquery.AddColumn(columns, "name", names, nil))
+	assert.NilError(t, smartquery.AddColumn(columns, "email", emails, validEmails))
+	assert.NilError(t, smartquery.AddColumn(columns, "balance", balances, nil))
+	assert.NilError(t, smartquery.AddColumn(columns, "stars", stars, validStars))
+	return records, columns
+}
+
+func TestSelectColumns(t *testing.T) {
+	records, columns := testColumns(t, 200)
+	registry := smartquery.NewRegistry[testStruct]()
+	_, err := registry.Register("rich", balanceField.Where(smartquery.Exact(9).AsRef()).AsRef())
+	assert.NilError(t, err)
+
+	queries := map[string]smartquery.Query[testStruct]{
+		"exact":     balanceField.Where(smartquery.Exact(3).AsRef()).AsRef(),
+		"like":      nameField.Where(smartquery.LikeString("user1%").AsRef()).AsRef(),
+		"option":    starsField.Where(smartquery.Exact(2).AsRef()).AsRef(),
+		"is none":   emailField.Where(smartquery.NewStringQuery(smartquery.MatchNone, optional.None[string]().AsRef()).AsRef()).AsRef(),
+		"not range": smartquery.Not[testStruct](starsField.Where(smartquery.Between(1, 3).AsRef()).AsRef()).AsRef(),
+		"combined": smartquery.Or[testStruct](
+			smartquery.And[testStruct](
+				emailField.Where(smartquery.LikeString("%5@example.com").AsRef()).AsRef(),
+				starsField.Where(smartquery.Any(0).AsRef()).AsRef(),
+			).AsRef(),
+			registry.Ref("rich").AsRef(),
+		).AsRef(),
+	}
+	for name, q := range queries {
+		t.Run(name, func(t *testing.T) {
+			expected := []int{}
+			for i, r := range records {
+				matched, err := q.Matches(r)
+				assert.NilError(t, err)
+				if matched {
+					expected = append(expected, i)
+				}
+			}
+
+			selected, err := smartquery.SelectColumns(q, columns)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, selected, expected)
+		})
+	}
+}
+
+func TestColumnErrors(t *testing.T) {
+	_, columns := testColumns(t, 10)
+
+	err := smartquery.AddColumn(columns, "short", []int{1, 2}, nil)
+	assert.ErrorContains(t, err, "column short has 2 rows, not 10")
+
+	_, err = smartquery.SelectColumns[testStruct](smartquery.NewField("missing", func(s testStruct) int { return 0 }).Where(smartquery.Exact(1).AsRef()).AsRef(), columns)
+	assert.ErrorContains(t, err, "no column named missing")
+
+	_, err = smartquery.SelectColumns[testStruct](smartquery.NewField("balance", func(s testStruct) string { return "" }).Where(smartquery.ExactString("1").AsRef()).AsRef(), columns)
+	assert.ErrorContains(t, err, "column balance does not hold values of type string")
+
+	predicate := smartquery.Predicate("rich", func(s testStruct) (bool, error) { return s.Balance > 5, nil })
+	_, err = smartquery.SelectColumns[testStruct](predicate.AsRef(), columns)
+	assert.ErrorContains(t, err, "cannot be evaluated on columns")
+
+	err = smartquery.MatchColumns[testStruct](balanceField.Where(smartquery.Exact(1).AsRef()).AsRef(), columns, nil)
+	assert.ErrorContains(t, err, "batch of 10 values only has room for 0 results")
+}
@@ -0,0 +1,150 @@
+package query
+
+import (
+	"github.com/brnsampson/optional"
+)
+
+// AndQuery matches when every child query matches. Children are evaluated in order and evaluation stops at the first
+// child that does not match or returns an error, the same as MatchAll.
+type AndQuery[T comparable] struct {
+	children []Query[T]
+}
+
+func And[T comparable](queries ...Query[T]) AndQuery[T] {
+	return AndQuery[T]{queries}
+}
+
+func (q AndQuery[T]) AsRef() *AndQuery[T] {
+	return &q
+}
+
+func (q *AndQuery[T]) Children() []Query[T] {
+	return q.children
+}
+
+func (q *AndQuery[T]) Matches(value T) (bool, error) {
+	return q.MatchesContext(nil, value)
+}
+
+func (q *AndQuery[T]) MatchesOption(value optional.Optional[T]) (bool, error) {
+	return q.MatchesOptionContext(nil, value)
+}
+
+func (q *AndQuery[T]) MatchesContext(ctx *EvalContext, value T) (bool, error) {
+	for _, child := range q.children {
+		matched, err := Evaluate(ctx, child, value)
+		if err != nil {
+			return false, err
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (q *AndQuery[T]) MatchesOptionContext(ctx *EvalContext, value optional.Optional[T]) (bool, error) {
+	for _, child := range q.children {
+		matched, err := EvaluateOption(ctx, child, value)
+		if err != nil {
+			return false, err
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// OrQuery matches when at least one child query matches. Children are evaluated in order and evaluation stops at the
+// first child that matches or returns an error.
+type OrQuery[T comparable] struct {
+	children []Query[T]
+}
+
+func Or[T comparable](queries ...Query[T]) OrQuery[T] {
+	return OrQuery[T]{queries}
+}
+
+func (q OrQuery[T]) AsRef() *OrQuery[T] {
+	return &q
+}
+
+func (q *OrQuery[T]) Children() []Query[T] {
+	return q.children
+}
+
+func (q *OrQuery[T]) Matches(value T) (bool, error) {
+	return q.MatchesContext(nil, value)
+}
+
+func (q *OrQuery[T]) MatchesOption(value optional.Optional[T]) (bool, error) {
+	return q.MatchesOptionContext(nil, value)
+}
+
+func (q *OrQuery[T]) MatchesContext(ctx *EvalContext, value T) (bool, error) {
+	for _, child := range q.children {
+		matched, err := Evaluate(ctx, child, value)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (q *OrQuery[T]) MatchesOptionContext(ctx *EvalContext, value optional.Optional[T]) (bool, error) {
+	for _, child := range q.children {
+		matched, err := EvaluateOption(ctx, child, value)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// NotQuery inverts the result of its child. Errors from the child are passed through unchanged.
+type NotQuery[T comparable] struct {
+	child Query[T]
+}
+
+func Not[T comparable](query Query[T]) NotQuery[T] {
+	return NotQuery[T]{query}
+}
+
+func (q NotQuery[T]) AsRef() *NotQuery[T] {
+	return &q
+}
+
+func (q *NotQuery[T]) Child() Query[T] {
+	return q.child
+}
+
+func (q *NotQuery[T]) Matches(value T) (bool, error) {
+	return q.MatchesContext(nil, value)
+}
+
+func (q *NotQuery[T]) MatchesOption(value optional.Optional[T]) (bool, error) {
+	return q.MatchesOptionContext(nil, value)
+}
+
+func (q *NotQuery[T]) MatchesContext(ctx *EvalContext, value T) (bool, error) {
+	matched, err := Evaluate(ctx, q.child, value)
+	if err != nil {
+		return false, err
+	}
+	return !matched, nil
+}
+
+func (q *NotQuery[T]) MatchesOptionContext(ctx *EvalContext, value optional.Optional[T]) (bool, error) {
+	matched, err := EvaluateOption(ctx, q.child, value)
+	if err != nil {
+		return false, err
+	}
+	return !matched, nil
+}
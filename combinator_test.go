@@ -0,0 +1,109 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestAndQuery(t *testing.T) {
+	both := smartquery.And[int](smartquery.AtLeast(40).AsRef(), smartquery.LessThan(50).AsRef())
+	some := optional.NewOption(47)
+	none := optional.None[int]()
+
+	matches, err := both.Matches(47)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "And query did not match when every child matched!")
+
+	matches, err = both.Matches(52)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "And query matched when one child did not match!")
+
+	matches, err = both.MatchesOption(&some)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "And query did not match option when every child matched!")
+
+	matches, err = both.MatchesOption(&none)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "And query matched option with None value!")
+
+	empty := smartquery.And[int]()
+	matches, err = empty.Matches(47)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "And query with no children should match everything!")
+
+	broken := smartquery.And[int](smartquery.Exact(47).AsRef(), smartquery.Like(47).AsRef())
+	_, err = broken.Matches(47)
+	assert.ErrorContains(t, err, "QueryError")
+
+	// Evaluation stops at the first child which does not match, so the error is never seen
+	shortCircuit := smartquery.And[int](smartquery.Exact(42).AsRef(), smartquery.Like(47).AsRef())
+	matches, err = shortCircuit.Matches(47)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "And query matched when the first child did not match!")
+}
+
+func TestOrQuery(t *testing.T) {
+	either := smartquery.Or[string](smartquery.ExactString("a").AsRef(), smartquery.LikeString("b%").AsRef())
+	some := optional.NewOption("bee")
+	none := optional.None[string]()
+
+	matches, err := either.Matches("a")
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Or query did not match when the first child matched!")
+
+	matches, err = either.Matches("bee")
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Or query did not match when the second child matched!")
+
+	matches, err = either.Matches("c")
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Or query matched when no children matched!")
+
+	matches, err = either.MatchesOption(&some)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Or query did not match option when a child matched!")
+
+	matches, err = either.MatchesOption(&none)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Or query matched option with None value!")
+
+	withNone := smartquery.Or[string](smartquery.ExactString("a").AsRef(), smartquery.NoneString("").AsRef())
+	matches, err = withNone.MatchesOption(&none)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Or query did not match None when a child matches None!")
+
+	empty := smartquery.Or[string]()
+	matches, err = empty.Matches("a")
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Or query with no children should match nothing!")
+}
+
+func TestNotQuery(t *testing.T) {
+	not := smartquery.Not[int](smartquery.Exact(47).AsRef())
+	some := optional.NewOption(47)
+	none := optional.None[int]()
+
+	matches, err := not.Matches(47)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Not query matched the value it negates!")
+
+	matches, err = not.Matches(42)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Not query did not match a different value!")
+
+	matches, err = not.MatchesOption(&some)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Not query matched option with the value it negates!")
+
+	matches, err = not.MatchesOption(&none)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Not query did not match option with None value!")
+
+	broken := smartquery.Not[int](smartquery.Like(47).AsRef())
+	matches, err = broken.Matches(47)
+	assert.ErrorContains(t, err, "QueryError")
+	assert.Assert(t, !matches, "Not query should not match when its child returns an error!")
+}
@@ -0,0 +1,219 @@
+package query
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/brnsampson/optional"
+)
+
+// EvalContext is the environment a query is evaluated in. It carries the clock used by relative time queries, the
+// collation used for Exact string comparisons and the variables referenced by VarQuery placeholders. Contexts are
+// immutable; the With methods return modified copies so a context can be shared between goroutines.
+//
+// A nil *EvalContext is valid and is the same as NewEvalContext(): the system clock, binary string comparison and no
+// variables. Evaluating a query with the plain Matches method uses that default context.
+type EvalContext struct {
+	clock     Clock
+	collation Collation
+	vars      map[string]any
+}
+
+func NewEvalContext() *EvalContext {
+	return &EvalContext{}
+}
+
+func (c *EvalContext) clone() *EvalContext {
+	if c == nil {
+		return &EvalContext{}
+	}
+	tmp := *c
+	return &tmp
+}
+
+func (c *EvalContext) WithClock(clock Clock) *EvalContext {
+	tmp := c.clone()
+	tmp.clock = clock
+	return tmp
+}
+
+func (c *EvalContext) WithCollation(collation Collation) *EvalContext {
+	tmp := c.clone()
+	tmp.collation = collation
+	return tmp
+}
+
+// WithVar binds a variable. The variable "now" does not need to be bound; it defaults to the current time of the
+// context's clock.
+func (c *EvalContext) WithVar(name string, value any) *EvalContext {
+	tmp := c.clone()
+	tmp.vars = maps.Clone(tmp.vars)
+	if tmp.vars == nil {
+		tmp.vars = make(map[string]any)
+	}
+	tmp.vars[name] = value
+	return tmp
+}
+
+func (c *EvalContext) Clock() Clock {
+	if c == nil || c.clock == nil {
+		return SystemClock
+	}
+	return c.clock
+}
+
+func (c *EvalContext) Now() time.Time {
+	return c.Clock().Now()
+}
+
+// Collation returns the collation for string comparisons, or nil for plain binary comparison.
+func (c *EvalContext) Collation() Collation {
+	if c == nil {
+		return nil
+	}
+	return c.collation
+}
+
+func (c *EvalContext) Var(name string) (any, bool) {
+	if c != nil {
+		if v, ok := c.vars[name]; ok {
+			return v, true
+		}
+	}
+	if name == "now" {
+		return c.Now(), true
+	}
+	return nil, false
+}
+
+// ContextQuery is implemented by queries whose result can depend on an EvalContext. Every query in this package which
+// either reads the context itself or contains other queries implements it. Use Evaluate rather than calling
+// MatchesContext directly so that plain queries work too.
+type ContextQuery[T comparable] interface {
+	Query[T]
+	MatchesContext(*EvalContext, T) (bool, error)
+	MatchesOptionContext(*EvalContext, optional.Optional[T]) (bool, error)
+}
+
+// Evaluate matches a value against a query in the given context. Queries which do not implement ContextQuery are
+// evaluated with Matches.
+func Evaluate[T comparable](ctx *EvalContext, query Query[T], value T) (bool, error) {
+	if cq, ok := query.(ContextQuery[T]); ok {
+		return cq.MatchesContext(ctx, value)
+	}
+	return query.Matches(value)
+}
+
+// EvaluateOption is the optional version of Evaluate.
+func EvaluateOption[T comparable](ctx *EvalContext, query Query[T], value optional.Optional[T]) (bool, error) {
+	if cq, ok := query.(ContextQuery[T]); ok {
+		return cq.MatchesOptionContext(ctx, value)
+	}
+	return query.MatchesOption(value)
+}
+
+// Collation compares strings for equality and order.
+type Collation interface {
+	Compare(a, b string) int
+}
+
+// CollationFunc adapts a comparison function to the Collation interface.
+type CollationFunc func(a, b string) int
+
+func (f CollationFunc) Compare(a, b string) int {
+	return f(a, b)
+}
+
+// BinaryCollation compares strings byte by byte. It is the same as having no collation.
+var BinaryCollation Collation = CollationFunc(strings.Compare)
+
+// FoldCaseCollation compares strings after Unicode simple case folding, so "Straße" and "STRASSE" are still different
+// but "Σ", "σ" and "ς" are equal.
+var FoldCaseCollation Collation = CollationFunc(func(a, b string) int {
+	return cmp.Compare(foldCase(a), foldCase(b))
+})
+
+// foldCase maps every rune to the smallest rune in its simple case folding orbit so that strings which are equal
+// under strings.EqualFold map to the same string.
+func foldCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		smallest := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if f < smallest {
+				smallest = f
+			}
+		}
+		return smallest
+	}, s)
+}
+
+// VarQuery is a placeholder for a query which is built from a variable of the EvalContext each time it is evaluated,
+// so a query can refer to things like the current user or the current time which are only known at evaluation time.
+type VarQuery[T comparable] struct {
+	name  string
+	build func(value any) (Query[T], error)
+}
+
+// WithVar creates a VarQuery which passes the value of the named variable to build. It is an error to evaluate the
+// query in a context where the variable is unbound or is not a V.
+func WithVar[T comparable, V any](name string, build func(V) Query[T]) VarQuery[T] {
+	return VarQuery[T]{name, func(value any) (Query[T], error) {
+		typed, ok := value.(V)
+		if !ok {
+			var zero V
+			return nil, fmt.Errorf("QueryError: variable $%s is a %T, not a %T", name, value, zero)
+		}
+		return build(typed), nil
+	}}
+}
+
+// ExactVar matches values which are equal to the named variable.
+func ExactVar[T comparable](name string) VarQuery[T] {
+	return WithVar(name, func(value T) Query[T] {
+		return Exact(value).AsRef()
+	})
+}
+
+func (q VarQuery[T]) AsRef() *VarQuery[T] {
+	return &q
+}
+
+func (q *VarQuery[T]) Name() string {
+	return q.name
+}
+
+func (q *VarQuery[T]) resolve(ctx *EvalContext) (Query[T], error) {
+	value, ok := ctx.Var(q.name)
+	if !ok {
+		return nil, fmt.Errorf("QueryError: variable $%s is not bound", q.name)
+	}
+	return q.build(value)
+}
+
+func (q *VarQuery[T]) Matches(value T) (bool, error) {
+	return q.MatchesContext(nil, value)
+}
+
+func (q *VarQuery[T]) MatchesOption(value optional.Optional[T]) (bool, error) {
+	return q.MatchesOptionContext(nil, value)
+}
+
+func (q *VarQuery[T]) MatchesContext(ctx *EvalContext, value T) (bool, error) {
+	resolved, err := q.resolve(ctx)
+	if err != nil {
+		return false, err
+	}
+	return Evaluate(ctx, resolved, value)
+}
+
+func (q *VarQuery[T]) MatchesOptionContext(ctx *EvalContext, value optional.Optional[T]) (bool, error) {
+	resolved, err := q.resolve(ctx)
+	if err != nil {
+		return false, err
+	}
+	return EvaluateOption(ctx, resolved, value)
+}
@@ -0,0 +1,111 @@
+package query_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestContextClock(t *testing.T) {
+	now := time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+	ctx := smartquery.NewEvalContext().WithClock(smartquery.FixedClock(now))
+
+	q := smartquery.Within(time.Hour).AsRef()
+	matches, err := smartquery.Evaluate[time.Time](ctx, q, now.Add(-30*time.Minute))
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Within query did not use the clock of the context!")
+
+	matches, err = smartquery.Evaluate[time.Time](ctx, q, now.Add(-2*time.Hour))
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Within query matched a time older than the context's clock allows!")
+
+	// A clock set on the query itself wins over the context
+	own := smartquery.Within(time.Hour).WithClock(smartquery.FixedClock(now.Add(-2 * time.Hour))).AsRef()
+	matches, err = smartquery.Evaluate[time.Time](ctx, own, now.Add(-2*time.Hour))
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Within query used the context's clock instead of its own!")
+
+	// $now defaults to the context's clock
+	value, ok := ctx.Var("now")
+	assert.Assert(t, ok)
+	assert.Equal(t, value, now)
+
+	var nilCtx *smartquery.EvalContext
+	_, ok = nilCtx.Var("now")
+	assert.Assert(t, ok, "nil context has no $now!")
+}
+
+func TestVarQuery(t *testing.T) {
+	alice := testStruct{Name: "alice", Email: optional.None[string]().AsRef(), Balance: 10, Stars: optional.None[int]().AsRef()}
+	bob := testStruct{Name: "bob", Email: optional.None[string]().AsRef(), Balance: 20, Stars: optional.None[int]().AsRef()}
+	records := []testStruct{alice, bob}
+
+	mine := nameField.Where(smartquery.ExactVar[string]("user").AsRef()).AsRef()
+	rich := balanceField.Where(smartquery.WithVar("min", func(min int) smartquery.Query[int] {
+		return smartquery.AtLeast(min).AsRef()
+	}).AsRef()).AsRef()
+	q := smartquery.And[testStruct](mine, rich).AsRef()
+
+	ctx := smartquery.NewEvalContext().WithVar("user", "bob").WithVar("min", 15)
+	found, err := smartquery.FilterContext[testStruct](ctx, records, q)
+	assert.NilError(t, err)
+	assert.Equal(t, len(found), 1)
+	assert.Equal(t, found[0].Name, "bob")
+
+	found, err = smartquery.FilterContext[testStruct](ctx.WithVar("user", "alice"), records, q)
+	assert.NilError(t, err)
+	assert.Equal(t, len(found), 0, "Var query matched alice with a balance below $min!")
+
+	// WithVar returns a copy, so the original context is unchanged
+	value, _ := ctx.Var("user")
+	assert.Equal(t, value, "bob")
+
+	_, err = q.Matches(bob)
+	assert.ErrorContains(t, err, "not bound")
+
+	_, err = smartquery.Evaluate[testStruct](ctx.WithVar("min", "lots"), q, bob)
+	assert.ErrorContains(t, err, "variable $min is a string")
+}
+
+func TestContextCollation(t *testing.T) {
+	ctx := smartquery.NewEvalContext().WithCollation(smartquery.FoldCaseCollation)
+	q := smartquery.ExactString("Chester").AsRef()
+
+	matches, err := q.Matches("CHESTER")
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Exact string query matched a different case without a collation!")
+
+	matches, err = smartquery.Evaluate[string](ctx, q, "CHESTER")
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Exact string query did not use the collation of the context!")
+
+	some := optional.NewOption("chester")
+	matches, err = smartquery.EvaluateOption[string](ctx, q, &some)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Exact string query did not use the collation for an option!")
+
+	none := optional.None[string]()
+	matches, err = smartquery.EvaluateOption[string](ctx, q, &none)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Exact string query matched None with a collation!")
+
+	// The context is passed down through combinators and fields
+	s := testStruct{Name: "CHESTER", Email: optional.None[string]().AsRef(), Balance: 42, Stars: optional.None[int]().AsRef()}
+	nested := smartquery.Not[testStruct](nameField.Where(q).AsRef()).AsRef()
+	matches, err = smartquery.Evaluate[testStruct](ctx, nested, s)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Collation was not passed through Not and a field predicate!")
+
+	// Indexes compare exactly, so an indexed collection has to fall back to a scan
+	c := smartquery.NewCollection[testStruct](smartquery.HashIndex(nameField))
+	c.Insert(s)
+	found, err := c.Find(nameField.Where(q).AsRef())
+	assert.NilError(t, err)
+	assert.Equal(t, len(found), 0)
+	found, err = c.FindContext(ctx, nameField.Where(q).AsRef())
+	assert.NilError(t, err)
+	assert.Equal(t, len(found), 1)
+}
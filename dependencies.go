@@ -0,0 +1,109 @@
+package query
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Dependencies returns the fields of the record which a query reads, for example to decide whether an update has to be
+// evaluated again. Fields are the names of Field, MapField and SliceField values, the paths of PathQuery and the
+// dotted paths of DocQuery. References to registered queries are followed to their current definition. Use Overlaps to
+// check whether a change to some fields affects them.
+//
+// The second result is false if some part of the query can read any part of the record, like a predicate which did not
+// declare what it reads or a query on the record as a whole. In that case the fields are only the ones which are known
+// to be read.
+func Dependencies[T comparable](query Query[T]) ([]string, bool) {
+	d := dependencies{fields: make(map[string]struct{}), complete: true}
+	addDependencies(&d, query)
+	return slices.Sorted(maps.Keys(d.fields)), d.complete
+}
+
+// Overlaps reports whether changing any of the changed fields can change the result of a query which reads the given
+// fields, as returned by Dependencies. Dotted paths overlap with the paths nested inside them and the paths they are
+// nested inside, so a change to "Address" affects a query on "Address.City" and a change to "Address.City" affects a
+// query on "Address".
+func Overlaps(fields, changed []string) bool {
+	for _, c := range changed {
+		for _, f := range fields {
+			if c == f || strings.HasPrefix(f, c+".") || strings.HasPrefix(c, f+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type dependencies struct {
+	fields   map[string]struct{}
+	complete bool
+}
+
+// dependent is implemented by queries which know which fields of the record they read.
+type dependent interface {
+	addDependencies(d *dependencies)
+}
+
+func addDependencies[T comparable](d *dependencies, query Query[T]) {
+	if q, ok := query.(dependent); ok {
+		q.addDependencies(d)
+		return
+	}
+	d.complete = false
+}
+
+func (q *AndQuery[T]) addDependencies(d *dependencies) {
+	for _, child := range q.children {
+		addDependencies(d, child)
+	}
+}
+
+func (q *OrQuery[T]) addDependencies(d *dependencies) {
+	for _, child := range q.children {
+		addDependencies(d, child)
+	}
+}
+
+func (q *NotQuery[T]) addDependencies(d *dependencies) {
+	addDependencies(d, q.child)
+}
+
+func (p *FieldPredicate[T, F]) addDependencies(d *dependencies) {
+	d.fields[p.field.name] = struct{}{}
+}
+
+func (p *MapPredicate[T, K, V]) addDependencies(d *dependencies) {
+	d.fields[p.field.name] = struct{}{}
+}
+
+func (p *SlicePredicate[T, E]) addDependencies(d *dependencies) {
+	d.fields[p.field.name] = struct{}{}
+}
+
+func (q *PathQuery[T, F]) addDependencies(d *dependencies) {
+	d.fields[q.path] = struct{}{}
+}
+
+func (q *DocQuery[F]) addDependencies(d *dependencies) {
+	d.fields[strings.Join(q.tokens, ".")] = struct{}{}
+}
+
+func (q *RefQuery[T]) addDependencies(d *dependencies) {
+	target, err := q.Resolve()
+	if err != nil {
+		d.complete = false
+		return
+	}
+	addDependencies(d, target)
+}
+
+func (q *PredicateQuery[T]) addDependencies(d *dependencies) {
+	if q.reads == nil {
+		d.complete = false
+		return
+	}
+	for _, f := range q.reads {
+		d.fields[f] = struct{}{}
+	}
+}
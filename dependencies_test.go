@@ -0,0 +1,92 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestDependencies(t *testing.T) {
+	tags := smartquery.NewSliceField("tags", func(s testStruct) []string { return nil })
+	labels := smartquery.NewMapField("labels", func(s testStruct) map[string]string { return nil })
+
+	q := smartquery.And[testStruct](
+		nameField.Where(smartquery.ExactString("Chester").AsRef()).AsRef(),
+		smartquery.Not[testStruct](smartquery.Or[testStruct](
+			starsField.Where(smartquery.AtLeast(3).AsRef()).AsRef(),
+			tags.Where(smartquery.AnyElement[string](smartquery.ExactString("x").AsRef())).AsRef(),
+		).AsRef()).AsRef(),
+		labels.Where(smartquery.HasKey[string, string]("env")).AsRef(),
+		// A field can appear more than once
+		nameField.Where(smartquery.LikeString("C%").AsRef()).AsRef(),
+	).AsRef()
+
+	fields, complete := smartquery.Dependencies[testStruct](q)
+	assert.Assert(t, complete)
+	assert.DeepEqual(t, fields, []string{"labels", "name", "stars", "tags"})
+
+	// Predicates are opaque unless they say what they read
+	opaque := smartquery.Predicate("rich", func(s testStruct) (bool, error) { return s.Balance > 100, nil })
+	fields, complete = smartquery.Dependencies[testStruct](smartquery.And[testStruct](q, opaque.AsRef()).AsRef())
+	assert.Assert(t, !complete)
+	assert.DeepEqual(t, fields, []string{"labels", "name", "stars", "tags"})
+
+	declared := opaque.Reading("balance")
+	fields, complete = smartquery.Dependencies[testStruct](declared.AsRef())
+	assert.Assert(t, complete)
+	assert.DeepEqual(t, fields, []string{"balance"})
+
+	// References are followed to their current definition
+	registry := smartquery.NewRegistry[testStruct]()
+	_, err := registry.Register("named", emailField.Where(smartquery.AnyString("").AsRef()).AsRef())
+	assert.NilError(t, err)
+	ref := registry.Ref("named")
+	fields, complete = smartquery.Dependencies[testStruct](ref.AsRef())
+	assert.Assert(t, complete)
+	assert.DeepEqual(t, fields, []string{"email"})
+
+	_, err = registry.Register("named", declared.AsRef())
+	assert.NilError(t, err)
+	fields, _ = smartquery.Dependencies[testStruct](ref.AsRef())
+	assert.DeepEqual(t, fields, []string{"balance"})
+
+	missing := registry.Ref("missing")
+	_, complete = smartquery.Dependencies[testStruct](missing.AsRef())
+	assert.Assert(t, !complete)
+}
+
+func TestPathDependencies(t *testing.T) {
+	q := smartquery.Or[any](
+		smartquery.DocPath("/user/name", smartquery.ExactString("x").AsRef()).AsRef(),
+		smartquery.DocPath("user.age", smartquery.AtLeast(18).AsRef()).AsRef(),
+	).AsRef()
+	fields, complete := smartquery.Dependencies[any](q)
+	assert.Assert(t, complete)
+	assert.DeepEqual(t, fields, []string{"user.age", "user.name"})
+
+	// A query on the value itself reads all of it
+	_, complete = smartquery.Dependencies[int](smartquery.Exact(1).AsRef())
+	assert.Assert(t, !complete)
+}
+
+func TestOverlaps(t *testing.T) {
+	fields := []string{"Address.City", "Name"}
+	cases := []struct {
+		changed  []string
+		overlaps bool
+	}{
+		{[]string{"Name"}, true},
+		{[]string{"Address.City"}, true},
+		{[]string{"Address"}, true},
+		{[]string{"Name.First"}, true},
+		{[]string{"Address.Zip"}, false},
+		{[]string{"Addr"}, false},
+		{[]string{"NameTag"}, false},
+		{[]string{"Billing", "Address"}, true},
+		{nil, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, smartquery.Overlaps(fields, c.changed), c.overlaps, "%v", c.changed)
+	}
+}
@@ -0,0 +1,165 @@
+package query
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/brnsampson/optional"
+)
+
+// DocQuery applies a typed query to a value inside of a dynamic document, like the map[string]any and []any trees
+// produced by encoding/json. The value is found by following a path, which is either a JSON pointer (RFC 6901) such as
+// "/items/0/sku" or a dotted path such as "items.0.sku". Missing keys, out of range indexes and JSON nulls are None.
+//
+// Strings, numbers and bools found in the document are coerced to the type the query expects. Numbers can be matched by
+// a query on any numeric type as long as the value can be represented exactly in that type (so 1.5 never matches a
+// query on int). Values which cannot be coerced simply do not match.
+type DocQuery[F comparable] struct {
+	path   string
+	tokens []string
+	query  Query[F]
+}
+
+func DocPath[F comparable](path string, query Query[F]) DocQuery[F] {
+	return DocQuery[F]{path, parseDocPath(path), query}
+}
+
+func (q DocQuery[F]) AsRef() *DocQuery[F] {
+	return &q
+}
+
+func (q *DocQuery[F]) Path() string {
+	return q.path
+}
+
+func (q *DocQuery[F]) Query() Query[F] {
+	return q.query
+}
+
+func (q *DocQuery[F]) Matches(doc any) (bool, error) {
+	return q.MatchesContext(nil, doc)
+}
+
+func (q *DocQuery[F]) MatchesOption(doc optional.Optional[any]) (bool, error) {
+	return q.MatchesOptionContext(nil, doc)
+}
+
+func (q *DocQuery[F]) MatchesContext(ctx *EvalContext, doc any) (bool, error) {
+	value, ok := lookupDoc(doc, q.tokens)
+	if !ok {
+		return EvaluateOption(ctx, q.query, optional.None[F]().AsRef())
+	}
+	coerced, ok := coerceDocValue[F](value)
+	if !ok {
+		return false, nil
+	}
+	return Evaluate(ctx, q.query, coerced)
+}
+
+func (q *DocQuery[F]) MatchesOptionContext(ctx *EvalContext, doc optional.Optional[any]) (bool, error) {
+	if doc.IsNone() {
+		return EvaluateOption(ctx, q.query, optional.None[F]().AsRef())
+	}
+	return q.MatchesContext(ctx, doc.UnsafeUnwrap())
+}
+
+func parseDocPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return strings.Split(path, ".")
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, t := range tokens {
+		// Order matters here, see RFC 6901 section 4
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens
+}
+
+// lookupDoc follows the path tokens through nested maps and slices. ok is false if anything along the path is missing
+// or null.
+func lookupDoc(doc any, tokens []string) (any, bool) {
+	current := doc
+	for _, t := range tokens {
+		switch c := current.(type) {
+		case map[string]any:
+			next, ok := c[t]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []any:
+			i, err := strconv.Atoi(t)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+			current = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, current != nil
+}
+
+// coerceDocValue converts a value found in a document into F if it can be done without losing information.
+func coerceDocValue[F comparable](value any) (F, bool) {
+	var zero F
+	if f, ok := value.(F); ok {
+		return f, true
+	}
+
+	target := reflect.TypeFor[F]()
+	if n, ok := value.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			value = i
+		} else if f, err := n.Float64(); err == nil {
+			value = f
+		} else {
+			return zero, false
+		}
+	}
+
+	v := reflect.ValueOf(value)
+	if isNumericKind(v.Kind()) && isNumericKind(target.Kind()) {
+		if !v.CanConvert(target) {
+			return zero, false
+		}
+		converted := v.Convert(target)
+		// Converting back has to give the original value, otherwise information was lost along the way. That doesn't
+		// catch a change of sign between signed and unsigned types, since -1 and MaxUint64 convert back and forth.
+		if isNegative(v) != isNegative(converted) {
+			return zero, false
+		}
+		if !converted.CanConvert(v.Type()) || converted.Convert(v.Type()).Interface() != v.Interface() {
+			return zero, false
+		}
+		return converted.Interface().(F), true
+	} else if v.Kind() == target.Kind() && (v.Kind() == reflect.String || v.Kind() == reflect.Bool) {
+		return v.Convert(target).Interface().(F), true
+	}
+	return zero, false
+}
+
+func isNegative(v reflect.Value) bool {
+	switch {
+	case v.CanInt():
+		return v.Int() < 0
+	case v.CanFloat():
+		return v.Float() < 0
+	}
+	return false
+}
+
+func isNumericKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
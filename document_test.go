@@ -0,0 +1,139 @@
+package query_test
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+const testDocument = `{
+	"event": "order.created",
+	"order": {
+		"id": 1234,
+		"total": 19.5,
+		"paid": true,
+		"coupon": null,
+		"items": [{"sku": "X-100", "qty": 2}, {"sku": "Y-200", "qty": 1}]
+	},
+	"a/b": {"m~n": "escaped"}
+}`
+
+func decodeTestDocument(t *testing.T, useNumber bool) any {
+	var doc any
+	d := json.NewDecoder(strings.NewReader(testDocument))
+	if useNumber {
+		d.UseNumber()
+	}
+	assert.NilError(t, d.Decode(&doc))
+	return doc
+}
+
+func TestDocQuery(t *testing.T) {
+	for _, useNumber := range []bool{false, true} {
+		doc := decodeTestDocument(t, useNumber)
+
+		cases := []struct {
+			name    string
+			query   smartquery.Query[any]
+			matches bool
+		}{
+			{"dotted string", smartquery.DocPath("event", smartquery.LikeString("order.%").AsRef()).AsRef(), true},
+			{"pointer string", smartquery.DocPath("/order/items/1/sku", smartquery.ExactString("Y-200").AsRef()).AsRef(), true},
+			{"dotted index", smartquery.DocPath("order.items.0.sku", smartquery.ExactString("X-100").AsRef()).AsRef(), true},
+			{"int", smartquery.DocPath("order.id", smartquery.Exact(1234).AsRef()).AsRef(), true},
+			{"int64", smartquery.DocPath("order.id", smartquery.Exact[int64](1234).AsRef()).AsRef(), true},
+			{"float", smartquery.DocPath("order.total", smartquery.GreaterThan(19.0).AsRef()).AsRef(), true},
+			{"inexact int", smartquery.DocPath("order.total", smartquery.Any(0).AsRef()).AsRef(), false},
+			{"bool", smartquery.DocPath("order.paid", smartquery.Exact(true).AsRef()).AsRef(), true},
+			{"wrong type", smartquery.DocPath("order.paid", smartquery.AnyString("").AsRef()).AsRef(), false},
+			{"null is none", smartquery.DocPath("order.coupon", smartquery.NoneString("").AsRef()).AsRef(), true},
+			{"missing is none", smartquery.DocPath("order.shipping.city", smartquery.NoneString("").AsRef()).AsRef(), true},
+			{"missing index is none", smartquery.DocPath("/order/items/5/sku", smartquery.NoneString("").AsRef()).AsRef(), true},
+			{"missing exact", smartquery.DocPath("order.shipping.city", smartquery.ExactString("Paris").AsRef()).AsRef(), false},
+			{"escaped pointer", smartquery.DocPath("/a~1b/m~0n", smartquery.ExactString("escaped").AsRef()).AsRef(), true},
+		}
+
+		for _, c := range cases {
+			matches, err := c.query.Matches(doc)
+			assert.NilError(t, err)
+			assert.Equal(t, matches, c.matches, "%s (UseNumber=%v)", c.name, useNumber)
+		}
+	}
+}
+
+func TestDocQueryMaps(t *testing.T) {
+	doc := map[string]any{"labels": map[string]any{"env": "prod"}, "replicas": 3}
+	some := optional.NewOption[any](doc)
+	none := optional.None[any]()
+
+	q := smartquery.And[any](
+		smartquery.DocPath("labels.env", smartquery.ExactString("prod").AsRef()).AsRef(),
+		smartquery.DocPath("replicas", smartquery.AtLeast[uint8](2).AsRef()).AsRef(),
+	)
+	matches, err := q.Matches(doc)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Doc query did not match a map built in Go!")
+
+	matches, err = q.MatchesOption(&some)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Doc query did not match an option with a document!")
+
+	missing := smartquery.DocPath("labels.env", smartquery.NoneString("").AsRef())
+	matches, err = missing.MatchesOption(&none)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Doc query on a None document did not match None!")
+
+	docs, err := smartquery.Filter([]any{doc, map[string]any{"labels": map[string]any{"env": "dev"}}}, q.AsRef())
+	assert.NilError(t, err)
+	assert.Equal(t, len(docs), 1)
+}
+
+func TestDocQuerySign(t *testing.T) {
+	for _, useNumber := range []bool{false, true} {
+		var doc any
+		d := json.NewDecoder(strings.NewReader(`{"delta": -1, "zero": -0.0}`))
+		if useNumber {
+			d.UseNumber()
+		}
+		assert.NilError(t, d.Decode(&doc))
+
+		cases := []struct {
+			name    string
+			query   smartquery.Query[any]
+			matches bool
+		}{
+			{"negative as uint64", smartquery.DocPath("delta", smartquery.Exact[uint64](math.MaxUint64).AsRef()).AsRef(), false},
+			{"negative as uint8", smartquery.DocPath("delta", smartquery.Exact[uint8](math.MaxUint8).AsRef()).AsRef(), false},
+			{"negative as any uint", smartquery.DocPath("delta", smartquery.Any[uint](0).AsRef()).AsRef(), false},
+			{"negative as int8", smartquery.DocPath("delta", smartquery.Exact[int8](-1).AsRef()).AsRef(), true},
+			{"negative zero as uint", smartquery.DocPath("zero", smartquery.Exact[uint](0).AsRef()).AsRef(), true},
+		}
+		for _, c := range cases {
+			matches, err := c.query.Matches(doc)
+			assert.NilError(t, err)
+			assert.Equal(t, matches, c.matches, "%s (UseNumber=%v)", c.name, useNumber)
+		}
+	}
+
+	// Large unsigned values in documents built in Go don't turn negative either
+	doc := map[string]any{"big": uint64(math.MaxUint64)}
+	matches, err := smartquery.DocPath("big", smartquery.Exact[int64](-1).AsRef()).AsRef().Matches(doc)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Doc query matched an unsigned value as a negative number!")
+}
+
+func TestDocQueryIgnoresIndexes(t *testing.T) {
+	doc := decodeTestDocument(t, false)
+	event := smartquery.NewField("event", func(d any) string { return "something else" })
+	collection := smartquery.NewCollection(smartquery.HashIndex(event))
+	collection.Insert(doc)
+
+	found, err := collection.FindIDs(smartquery.DocPath("event", smartquery.ExactString("order.created").AsRef()).AsRef())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, []int{0})
+}
@@ -0,0 +1,101 @@
+package query
+
+import (
+	"github.com/brnsampson/optional"
+)
+
+// Field names a value of type F that can be extracted from a record of type T. It is used to lift a query on the field
+// into a query on the whole record and to declare indexes. The name is how indexes and query plans refer to the field,
+// so it should be unique among the fields of T.
+type Field[T comparable, F comparable] struct {
+	name   string
+	value  func(T) F
+	option func(T) optional.Optional[F]
+}
+
+// NewField creates a Field for a plain (non-optional) value of the record.
+func NewField[T comparable, F comparable](name string, value func(T) F) Field[T, F] {
+	return Field[T, F]{name: name, value: value}
+}
+
+// NewOptionField creates a Field for an optional value of the record. Queries against the field are evaluated with
+// MatchesOption so None is handled the same way it would be when querying the value directly.
+func NewOptionField[T comparable, F comparable](name string, option func(T) optional.Optional[F]) Field[T, F] {
+	return Field[T, F]{name: name, option: option}
+}
+
+func (f Field[T, F]) Name() string {
+	return f.name
+}
+
+func (f Field[T, F]) IsOption() bool {
+	return f.option != nil
+}
+
+// Get returns the value of the field for the given record. Plain fields are always Some.
+func (f Field[T, F]) Get(record T) optional.Optional[F] {
+	if f.option != nil {
+		return f.option(record)
+	}
+	return optional.NewOption(f.value(record)).AsRef()
+}
+
+// Where creates a query which matches records whose field matches the given query.
+func (f Field[T, F]) Where(query Query[F]) FieldPredicate[T, F] {
+	return FieldPredicate[T, F]{f, query}
+}
+
+type FieldPredicate[T comparable, F comparable] struct {
+	field Field[T, F]
+	query Query[F]
+}
+
+func (p FieldPredicate[T, F]) AsRef() *FieldPredicate[T, F] {
+	return &p
+}
+
+func (p *FieldPredicate[T, F]) Field() Field[T, F] {
+	return p.field
+}
+
+func (p *FieldPredicate[T, F]) Query() Query[F] {
+	return p.query
+}
+
+func (p *FieldPredicate[T, F]) Matches(record T) (bool, error) {
+	return p.MatchesContext(nil, record)
+}
+
+func (p *FieldPredicate[T, F]) MatchesOption(record optional.Optional[T]) (bool, error) {
+	return p.MatchesOptionContext(nil, record)
+}
+
+func (p *FieldPredicate[T, F]) MatchesContext(ctx *EvalContext, record T) (bool, error) {
+	if p.field.option != nil {
+		return EvaluateOption(ctx, p.query, p.field.option(record))
+	}
+	return Evaluate(ctx, p.query, p.field.value(record))
+}
+
+func (p *FieldPredicate[T, F]) MatchesOptionContext(ctx *EvalContext, record optional.Optional[T]) (bool, error) {
+	if record.IsNone() {
+		return false, nil
+	}
+
+	return p.MatchesContext(ctx, record.UnsafeUnwrap())
+}
+
+// fieldPredicate allows code which only knows the record type (like the Collection query planner) to find out which
+// field a predicate is on and what query it applies to that field.
+type fieldPredicate interface {
+	fieldName() string
+	fieldQuery() any
+}
+
+func (p *FieldPredicate[T, F]) fieldName() string {
+	return p.field.name
+}
+
+func (p *FieldPredicate[T, F]) fieldQuery() any {
+	return p.query
+}
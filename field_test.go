@@ -0,0 +1,55 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+var (
+	nameField    = smartquery.NewField("name", func(s testStruct) string { return s.Name })
+	emailField   = smartquery.NewOptionField("email", func(s testStruct) optional.Optional[string] { return s.Email })
+	balanceField = smartquery.NewField("balance", func(s testStruct) int { return s.Balance })
+	starsField   = smartquery.NewOptionField("stars", func(s testStruct) optional.Optional[int] { return s.Stars })
+)
+
+func TestFieldPredicate(t *testing.T) {
+	s := testStruct{Name: "Chester the Tester", Email: optional.None[string]().AsRef(), Balance: 42, Stars: optional.NewOption(7).AsRef()}
+	some := optional.NewOption(s)
+	none := optional.None[testStruct]()
+
+	name := nameField.Where(smartquery.LikeString("Chester%").AsRef())
+	matches, err := name.Matches(s)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Field predicate did not match the field value!")
+
+	matches, err = name.MatchesOption(&some)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Field predicate did not match the field value of an option!")
+
+	matches, err = name.MatchesOption(&none)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Field predicate matched an option with None value!")
+
+	email := emailField.Where(smartquery.NoneString("").AsRef())
+	matches, err = email.Matches(s)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "None query on an option field did not match None!")
+
+	stars := starsField.Where(smartquery.Exact(7).AsRef())
+	matches, err = stars.Matches(s)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Exact query on an option field did not match!")
+
+	balance := balanceField.Where(smartquery.Like(42).AsRef())
+	_, err = balance.Matches(s)
+	assert.ErrorContains(t, err, "QueryError")
+
+	assert.Equal(t, nameField.Name(), "name")
+	assert.Assert(t, !nameField.IsOption())
+	assert.Assert(t, emailField.IsOption())
+	assert.Assert(t, emailField.Get(s).IsNone())
+	assert.Equal(t, balanceField.Get(s).UnsafeUnwrap(), 42)
+}
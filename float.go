@@ -0,0 +1,95 @@
+package query
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/brnsampson/optional"
+)
+
+// Float is the constraint for the float types accepted by FloatQuery.
+type Float interface {
+	~float32 | ~float64
+}
+
+type FloatMatchType int
+
+const (
+	// Float matching operations. Exact on a FieldQuery compares floats with ==, which is rarely what you want for
+	// computed values; use MatchApprox instead.
+	MatchApprox FloatMatchType = iota // True if the value is within an absolute or relative tolerance of the target
+	MatchNaN                          // True if the value is NaN
+	MatchInf                          // True if the value is infinite, optionally with a given sign
+	MatchFinite                       // True if the value is neither NaN nor infinite
+)
+
+// FloatQuery matches float values by properties that == can't express. None never matches.
+type FloatQuery[T Float] struct {
+	criteria FloatMatchType
+	value    T
+	absTol   float64
+	relTol   float64
+	sign     int
+}
+
+// Approx matches values within absTol of value, or within relTol times the larger magnitude of the two, whichever is
+// looser. This is the same test as Python's math.isclose. NaN is never close to anything, and infinities are only close
+// to themselves.
+func Approx[T Float](value T, absTol, relTol float64) FloatQuery[T] {
+	return FloatQuery[T]{criteria: MatchApprox, value: value, absTol: absTol, relTol: relTol}
+}
+
+func IsNaN[T Float]() FloatQuery[T] {
+	return FloatQuery[T]{criteria: MatchNaN}
+}
+
+// IsInf matches +Inf if sign > 0, -Inf if sign < 0 and either if sign == 0, the same as math.IsInf.
+func IsInf[T Float](sign int) FloatQuery[T] {
+	return FloatQuery[T]{criteria: MatchInf, sign: sign}
+}
+
+func IsFinite[T Float]() FloatQuery[T] {
+	return FloatQuery[T]{criteria: MatchFinite}
+}
+
+func (q FloatQuery[T]) AsRef() *FloatQuery[T] {
+	return &q
+}
+
+func (q *FloatQuery[T]) Matches(value T) (bool, error) {
+	c := q.criteria
+	v := float64(value)
+	if c == MatchApprox {
+		if q.absTol < 0 || q.relTol < 0 || math.IsNaN(q.absTol) || math.IsNaN(q.relTol) {
+			return false, fmt.Errorf("QueryError: invalid float tolerances %v and %v", q.absTol, q.relTol)
+		}
+		return approxEqual(v, float64(q.value), q.absTol, q.relTol), nil
+	} else if c == MatchNaN {
+		return math.IsNaN(v), nil
+	} else if c == MatchInf {
+		return math.IsInf(v, q.sign), nil
+	} else if c == MatchFinite {
+		return !math.IsNaN(v) && !math.IsInf(v, 0), nil
+	}
+	return false, fmt.Errorf("QueryError: unsupported float matching strategy: %d", c)
+}
+
+func (q *FloatQuery[T]) MatchesOption(value optional.Optional[T]) (bool, error) {
+	if value.IsNone() {
+		return false, nil
+	}
+	return q.Matches(value.UnsafeUnwrap())
+}
+
+func approxEqual(a, b, absTol, relTol float64) bool {
+	if a == b {
+		// Also covers infinities of the same sign
+		return true
+	}
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return false
+	}
+	diff := math.Abs(a - b)
+	// diff is NaN if either side is, and NaN <= x is false
+	return diff <= absTol || diff <= relTol*max(math.Abs(a), math.Abs(b))
+}
@@ -0,0 +1,90 @@
+package query_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestFloatQuery(t *testing.T) {
+	nan := math.NaN()
+	inf := math.Inf(1)
+
+	cases := []struct {
+		name    string
+		query   smartquery.FloatQuery[float64]
+		value   float64
+		matches bool
+	}{
+		{"approx sum", smartquery.Approx(0.3, 1e-9, 0), 0.1 + 0.2, true},
+		{"approx too far", smartquery.Approx(0.3, 1e-9, 0), 0.31, false},
+		{"approx relative", smartquery.Approx(1e9, 0, 1e-6), 1e9 + 100, true},
+		{"approx relative too far", smartquery.Approx(1e9, 0, 1e-6), 1e9 + 10000, false},
+		{"approx nan", smartquery.Approx(nan, 1, 1), nan, false},
+		{"approx inf", smartquery.Approx(inf, 1, 1), inf, true},
+		{"approx inf other sign", smartquery.Approx(inf, 1, 1), -inf, false},
+		{"approx large value to inf", smartquery.Approx(math.MaxFloat64, 0, 0.5), inf, false},
+		{"nan", smartquery.IsNaN[float64](), nan, true},
+		{"not nan", smartquery.IsNaN[float64](), 1, false},
+		{"inf", smartquery.IsInf[float64](0), -inf, true},
+		{"positive inf", smartquery.IsInf[float64](1), -inf, false},
+		{"finite", smartquery.IsFinite[float64](), 1, true},
+		{"finite nan", smartquery.IsFinite[float64](), nan, false},
+		{"finite inf", smartquery.IsFinite[float64](), inf, false},
+	}
+
+	for _, c := range cases {
+		matches, err := c.query.Matches(c.value)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, c.name)
+
+		some := optional.NewOption(c.value)
+		matches, err = c.query.MatchesOption(&some)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, c.name)
+
+		none := optional.None[float64]()
+		matches, err = c.query.MatchesOption(&none)
+		assert.NilError(t, err)
+		assert.Assert(t, !matches, "%s: float query matched option with None value!", c.name)
+	}
+
+	bad := smartquery.Approx(1.0, -1, 0)
+	_, err := bad.Matches(1)
+	assert.ErrorContains(t, err, "invalid float tolerances")
+
+	small := smartquery.Approx[float32](0.3, 1e-6, 0)
+	matches, err := small.Matches(0.1 + 0.2)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "float32 approx query did not match!")
+}
+
+func TestRangeNaNOrder(t *testing.T) {
+	nan := math.NaN()
+
+	cases := []struct {
+		name    string
+		query   smartquery.RangeQuery[float64]
+		value   float64
+		matches bool
+	}{
+		{"unordered", smartquery.AtMost(math.Inf(1)), nan, false},
+		{"smallest below", smartquery.AtMost(0.0).OrderNaN(smartquery.NaNSmallest), nan, true},
+		{"smallest above", smartquery.AtLeast(math.Inf(-1)).OrderNaN(smartquery.NaNSmallest), nan, false},
+		{"largest above", smartquery.GreaterThan(0.0).OrderNaN(smartquery.NaNLargest), nan, true},
+		{"largest below", smartquery.LessThan(math.Inf(1)).OrderNaN(smartquery.NaNLargest), nan, false},
+		{"nan bound", smartquery.AtLeast(nan).OrderNaN(smartquery.NaNLargest), nan, true},
+		{"nan bound exclusive", smartquery.GreaterThan(nan).OrderNaN(smartquery.NaNLargest), nan, false},
+		{"nan bound number", smartquery.LessThan(nan).OrderNaN(smartquery.NaNLargest), 1e300, true},
+		{"ordinary", smartquery.Between(1.0, 2.0).OrderNaN(smartquery.NaNSmallest), 1.5, true},
+	}
+
+	for _, c := range cases {
+		matches, err := c.query.Matches(c.value)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, c.name)
+	}
+}
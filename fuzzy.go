@@ -0,0 +1,261 @@
+package query
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"unicode"
+
+	"github.com/brnsampson/optional"
+)
+
+// fuzzy reports whether c is one of the approximate string matching strategies: MatchEditDistance, MatchJaroWinkler or
+// MatchTrigram.
+func (c MatchType) fuzzy() bool {
+	return c == MatchEditDistance || c == MatchJaroWinkler || c == MatchTrigram
+}
+
+// WithinEdits matches strings which can be turned into target with at most k single rune insertions, deletions or
+// substitutions. Edit distance works on runes and is case sensitive unless the query is normalized.
+func WithinEdits(target string, k int) StringQuery {
+	q := NewStringQuery(MatchEditDistance, optional.NewOption(target).AsRef())
+	q.distance = k
+	return q
+}
+
+// JaroWinkler matches strings whose Jaro-Winkler similarity to target is at least threshold, which is between 0 and 1.
+// Like edit distance it works on runes and is case sensitive unless the query is normalized.
+func JaroWinkler(target string, threshold float64) StringQuery {
+	q := NewStringQuery(MatchJaroWinkler, optional.NewOption(target).AsRef())
+	q.threshold = threshold
+	return q
+}
+
+// Trigram matches strings whose trigram similarity to target is at least threshold, which is between 0 and 1. pg_trgm
+// uses 0.3 by default. Trigram similarity follows pg_trgm: it ignores case and anything other than letters and digits,
+// and compares the sets of trigrams of each word.
+func Trigram(target string, threshold float64) StringQuery {
+	q := NewStringQuery(MatchTrigram, optional.NewOption(target).AsRef())
+	q.threshold = threshold
+	return q
+}
+
+// Score returns the similarity of value to the target of a fuzzy query from 0 (nothing in common) to 1 (identical), so
+// matches can be ranked. For edit distance this is 1 - distance / the length of the longer string in runes. The value
+// is normalized first if the query is.
+func (q *StringQuery) Score(value string) (float64, error) {
+	if !q.criteria.fuzzy() {
+		return 0, fmt.Errorf("QueryError: cannot score matching strategy %d, only fuzzy matches have a score", q.criteria)
+	}
+	return q.score(q.normalization.apply(value)), nil
+}
+
+// score compares an already normalized value to the target of a fuzzy query.
+func (q *StringQuery) score(value string) float64 {
+	c := q.criteria
+	if c == MatchTrigram {
+		var buf [fuzzyBufferSize]trigram
+		return trigramSimilarity(q.trigrams, trigrams(value, buf[:0]))
+	}
+
+	var buf [fuzzyBufferSize]rune
+	b := appendRunes(buf[:0], value)
+	if c == MatchEditDistance {
+		longest := max(len(q.runes), len(b))
+		if longest == 0 {
+			return 1
+		}
+		return 1 - float64(levenshtein(q.runes, b, longest))/float64(longest)
+	}
+	return jaroWinkler(q.runes, b)
+}
+
+// checkFuzzy returns an error if the edit distance or threshold of a fuzzy query is out of range.
+func (q *StringQuery) checkFuzzy() error {
+	if q.criteria == MatchEditDistance {
+		if q.distance < 0 {
+			return fmt.Errorf("QueryError: invalid edit distance %d", q.distance)
+		}
+	} else if !(q.threshold >= 0 && q.threshold <= 1) {
+		return fmt.Errorf("QueryError: invalid similarity threshold %v", q.threshold)
+	}
+	return nil
+}
+
+// matchFuzzy matches an already normalized value against a fuzzy query.
+func (q *StringQuery) matchFuzzy(value string) (bool, error) {
+	if err := q.checkFuzzy(); err != nil {
+		return false, err
+	}
+	if q.criteria == MatchEditDistance {
+		var buf [fuzzyBufferSize]rune
+		return levenshtein(q.runes, appendRunes(buf[:0], value), q.distance) <= q.distance, nil
+	}
+	return q.score(value) >= q.threshold, nil
+}
+
+// fuzzyBufferSize is the number of runes (or trigrams) of a value which fuzzy matching keeps on the stack. Only longer
+// values allocate.
+const fuzzyBufferSize = 64
+
+func appendRunes(dst []rune, s string) []rune {
+	for _, r := range s {
+		dst = append(dst, r)
+	}
+	return dst
+}
+
+// levenshtein returns the edit distance between a and b, or some number larger than limit once it is clear that the
+// distance is larger than limit.
+func levenshtein(a, b []rune, limit int) int {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	if len(a)-len(b) > limit {
+		return limit + 1
+	}
+
+	var rows [2][fuzzyBufferSize + 1]int
+	prev, curr := rows[0][:], rows[1][:]
+	if len(b) >= len(prev) {
+		prev, curr = make([]int, len(b)+1), make([]int, len(b)+1)
+	}
+	prev, curr = prev[:len(b)+1], curr[:len(b)+1]
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		best := curr[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			best = min(best, curr[j])
+		}
+		if best > limit {
+			return limit + 1
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// jaroWinkler uses the usual prefix scale of 0.1 for a common prefix of up to 4 runes.
+func jaroWinkler(a, b []rune) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	var flags [2][fuzzyBufferSize]bool
+	aMatched, bMatched := flags[0][:], flags[1][:]
+	if len(a) > len(aMatched) || len(b) > len(bMatched) {
+		aMatched, bMatched = make([]bool, len(a)), make([]bool, len(b))
+	}
+	aMatched, bMatched = aMatched[:len(a)], bMatched[:len(b)]
+
+	window := max(len(a), len(b))/2 - 1
+	window = max(window, 0)
+	matches := 0
+	for i := range a {
+		for j := max(0, i-window); j < min(len(b), i+window+1); j++ {
+			if !bMatched[j] && a[i] == b[j] {
+				aMatched[i], bMatched[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+	j := 0
+	for i := range a {
+		if !aMatched[i] {
+			continue
+		}
+		for !bMatched[j] {
+			j++
+		}
+		if a[i] != b[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(a)) + m/float64(len(b)) + (m-float64(transpositions/2))/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(a), len(b)) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+type trigram [3]rune
+
+// trigrams appends the distinct trigrams of s to dst in sorted order the same way pg_trgm finds them: s is lower cased
+// and split into words of letters and digits, and each word is padded with two spaces in front and one behind.
+func trigrams(s string, dst []trigram) []trigram {
+	start := len(dst)
+	window := [2]rune{' ', ' '}
+	inWord := false
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if inWord {
+				dst = append(dst, trigram{window[0], window[1], ' '})
+				window, inWord = [2]rune{' ', ' '}, false
+			}
+			continue
+		}
+		r = unicode.ToLower(r)
+		dst = append(dst, trigram{window[0], window[1], r})
+		window, inWord = [2]rune{window[1], r}, true
+	}
+	if inWord {
+		dst = append(dst, trigram{window[0], window[1], ' '})
+	}
+
+	found := dst[start:]
+	slices.SortFunc(found, compareTrigrams)
+	return dst[:start+len(slices.Compact(found))]
+}
+
+func compareTrigrams(a, b trigram) int {
+	for i := range a {
+		if c := cmp.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// trigramSimilarity is the number of shared trigrams divided by the number of distinct trigrams in either set. Both
+// must be sorted and distinct, as returned by trigrams.
+func trigramSimilarity(a, b []trigram) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch c := compareTrigrams(a[i], b[j]); {
+		case c < 0:
+			i++
+		case c > 0:
+			j++
+		default:
+			shared++
+			i++
+			j++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
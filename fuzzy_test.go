@@ -0,0 +1,103 @@
+package query_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestFuzzyQuery(t *testing.T) {
+	cases := []struct {
+		name    string
+		query   smartquery.StringQuery
+		value   string
+		matches bool
+	}{
+		{"edits", smartquery.WithinEdits("kitten", 3), "sitting", true},
+		{"too many edits", smartquery.WithinEdits("kitten", 2), "sitting", false},
+		{"no edits", smartquery.WithinEdits("kitten", 0), "kitten", true},
+		{"edits runes", smartquery.WithinEdits("straße", 1), "strase", true},
+		{"edits length", smartquery.WithinEdits("a", 2), "abcd", false},
+		{"jaro winkler", smartquery.JaroWinkler("MARTHA", 0.96), "MARHTA", true},
+		{"jaro winkler low", smartquery.JaroWinkler("DWAYNE", 0.9), "DUANE", false},
+		{"trigram", smartquery.Trigram("word", 0.5), "Words!", true},
+		{"trigram low", smartquery.Trigram("word", 0.3), "sword fish", false},
+		{"trigram empty", smartquery.Trigram("", 0), "", true},
+		// Long enough that the buffers are not on the stack
+		{"edits long", smartquery.WithinEdits(strings.Repeat("ab", 50), 1), strings.Repeat("ab", 49) + "aa", true},
+		{"jaro winkler long", smartquery.JaroWinkler(strings.Repeat("ab", 50), 0.99), strings.Repeat("ab", 49) + "aa", true},
+		{"trigram long", smartquery.Trigram(strings.Repeat("word ", 100)+"other", 0.5), "other words", true},
+	}
+
+	for _, c := range cases {
+		matches, err := c.query.Matches(c.value)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, c.name)
+
+		some := optional.NewOption(c.value)
+		matches, err = c.query.MatchesOption(&some)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, c.name)
+
+		none := optional.None[string]()
+		matches, err = c.query.MatchesOption(&none)
+		assert.NilError(t, err)
+		assert.Assert(t, !matches, "%s: fuzzy query matched option with None value!", c.name)
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	cases := []struct {
+		name  string
+		query smartquery.StringQuery
+		value string
+		score float64
+	}{
+		{"edits", smartquery.WithinEdits("kitten", 3), "sitting", 1 - 3.0/7},
+		{"edits empty", smartquery.WithinEdits("", 3), "", 1},
+		{"jaro winkler", smartquery.JaroWinkler("MARTHA", 0), "MARHTA", 0.9611},
+		{"jaro winkler dwayne", smartquery.JaroWinkler("DWAYNE", 0), "DUANE", 0.84},
+		{"jaro winkler dixon", smartquery.JaroWinkler("DIXON", 0), "DICKSONX", 0.8133},
+		{"jaro winkler nothing", smartquery.JaroWinkler("abc", 0), "xyz", 0},
+		{"trigram", smartquery.Trigram("word", 0), "words", 4.0 / 7},
+	}
+
+	for _, c := range cases {
+		score, err := c.query.Score(c.value)
+		assert.NilError(t, err)
+		assert.Assert(t, math.Abs(score-c.score) < 1e-4, "%s: expected score %v, got %v", c.name, c.score, score)
+	}
+
+	q := smartquery.JaroWinkler("abc", 1.5)
+	_, err := q.Matches("abc")
+	assert.ErrorContains(t, err, "invalid similarity threshold")
+
+	q = smartquery.WithinEdits("abc", -1)
+	_, err = q.Matches("abc")
+	assert.ErrorContains(t, err, "invalid edit distance")
+}
+
+func TestFuzzyNormalization(t *testing.T) {
+	q := smartquery.WithinEdits("Crème Brûlée", 1).WithNormalization(smartquery.NormalizeCase | smartquery.NormalizeAccents)
+	matches, err := q.Matches("CREME BRULE")
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Normalized edit distance query did not ignore case and accents!")
+
+	score, err := q.Score("creme brulee")
+	assert.NilError(t, err)
+	assert.Equal(t, score, 1.0)
+
+	// Trigrams of the target are worked out again after it is normalized
+	trigram := smartquery.Trigram("Ünïcode", 1).WithNormalization(smartquery.NormalizeAccents)
+	matches, err = trigram.Matches("unicode")
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Normalized trigram query did not match the normalized target!")
+
+	exact := smartquery.ExactString("abc")
+	_, err = exact.Score("abc")
+	assert.ErrorContains(t, err, "cannot score")
+}
@@ -0,0 +1,147 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/brnsampson/optional"
+)
+
+// GlobString creates a MatchGlob query with the same syntax as path.Match: * matches any sequence of characters other
+// than /, ? matches any single character other than /, [abc] matches any character in the class and \c matches the
+// character c. Classes may have ranges like [a-z] and are negated with [^abc]. The whole string has to match the
+// pattern. The pattern is compiled here and an invalid pattern is an error.
+func GlobString(pattern string) (StringQuery, error) {
+	return newGlobQuery(pattern, false)
+}
+
+// GlobPathString is GlobString with one addition: a ** path segment matches zero or more whole path segments, so
+// "logs/**/*.gz" matches "logs/a.gz" and "logs/2024/06/a.gz". ** anywhere other than as a whole segment is an error.
+func GlobPathString(pattern string) (StringQuery, error) {
+	return newGlobQuery(pattern, true)
+}
+
+func newGlobQuery(pattern string, globstar bool) (StringQuery, error) {
+	q := StringQuery{criteria: MatchGlob, value: optional.NewOption(pattern).AsRef(), globstar: globstar}
+	q.snapshot()
+	re, err := q.compile()
+	if err != nil {
+		return StringQuery{}, err
+	}
+	q.pattern = re
+	return q, nil
+}
+
+// globPattern translates a glob into an anchored regexp.
+func globPattern(pattern string, globstar bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch r {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' && globstar {
+				start := i == 0 || runes[i-1] == '/'
+				end := i+2 == len(runes) || runes[i+2] == '/'
+				if !start || !end {
+					return nil, fmt.Errorf("QueryError: invalid glob pattern %q: ** must be a whole path segment", pattern)
+				}
+				i++
+				if i+1 == len(runes) {
+					// Trailing ** matches everything below, including nothing at all if it follows a /
+					b.WriteString(".*")
+				} else {
+					// **/ matches zero or more leading segments
+					b.WriteString("(?:.*/)?")
+					i++
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end, class, err := globClass(runes, i)
+			if err != nil {
+				return nil, fmt.Errorf("QueryError: invalid glob pattern %q: %w", pattern, err)
+			}
+			b.WriteString(class)
+			i = end
+		case '\\':
+			if i+1 == len(runes) {
+				return nil, fmt.Errorf("QueryError: invalid glob pattern %q: trailing backslash", pattern)
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// globClass translates the character class starting at runes[start] and returns the index of its closing ].
+func globClass(runes []rune, start int) (int, string, error) {
+	var b strings.Builder
+	b.WriteString("[")
+	i := start + 1
+	if i < len(runes) && runes[i] == '^' {
+		b.WriteString("^")
+		i++
+	}
+
+	empty := true
+	for ; i < len(runes); i++ {
+		r := runes[i]
+		if r == ']' {
+			if empty {
+				return 0, "", fmt.Errorf("empty character class")
+			}
+			b.WriteString("]")
+			return i, b.String(), nil
+		}
+		lo, next, err := globClassChar(runes, i)
+		if err != nil {
+			return 0, "", err
+		}
+		i = next
+		b.WriteString(escapeClassChar(lo))
+		if i+2 < len(runes) && runes[i+1] == '-' && runes[i+2] != ']' {
+			hi, next, err := globClassChar(runes, i+2)
+			if err != nil {
+				return 0, "", err
+			}
+			if hi < lo {
+				return 0, "", fmt.Errorf("character range %c-%c is out of order", lo, hi)
+			}
+			i = next
+			b.WriteString("-")
+			b.WriteString(escapeClassChar(hi))
+		}
+		empty = false
+	}
+	return 0, "", fmt.Errorf("unterminated character class")
+}
+
+// globClassChar reads one, possibly escaped, character of a class and returns it along with the index of its last rune.
+func globClassChar(runes []rune, i int) (rune, int, error) {
+	r := runes[i]
+	if r == '\\' {
+		if i+1 == len(runes) {
+			return 0, 0, fmt.Errorf("trailing backslash")
+		}
+		i++
+		r = runes[i]
+	}
+	return r, i, nil
+}
+
+func escapeClassChar(r rune) string {
+	if strings.ContainsRune(`\]-[^`, r) {
+		return `\` + string(r)
+	}
+	return string(r)
+}
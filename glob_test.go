@@ -0,0 +1,121 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestGlobString(t *testing.T) {
+	cases := []struct {
+		pattern string
+		value   string
+		matches bool
+	}{
+		{"*.example.com", "api.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "api.example.com.evil.org", false},
+		{"log-??-[0-9]", "log-ab-7", true},
+		{"log-??-[0-9]", "log-abc-7", false},
+		{"log-??-[0-9]", "log-ab-x", false},
+		{"[^a-c]x", "dx", true},
+		{"[^a-c]x", "bx", false},
+		{"a[\\]]b", "a]b", true},
+		{"a\\*b", "a*b", true},
+		{"a\\*b", "axb", false},
+		{"a.b", "axb", false},
+		{"*", "a/b", false},
+		{"a?c", "a/c", false},
+		{"ünï?ode", "ünïcode", true},
+		{"", "", true},
+	}
+
+	for _, c := range cases {
+		q, err := smartquery.GlobString(c.pattern)
+		assert.NilError(t, err, c.pattern)
+
+		matches, err := q.Matches(c.value)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, "%s against %s", c.pattern, c.value)
+
+		some := optional.NewOption(c.value)
+		matches, err = q.MatchesOption(&some)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, "%s against %s", c.pattern, c.value)
+
+		none := optional.None[string]()
+		matches, err = q.MatchesOption(&none)
+		assert.NilError(t, err)
+		assert.Assert(t, !matches, "%s: glob query matched option with None value!", c.pattern)
+	}
+}
+
+func TestGlobPathString(t *testing.T) {
+	cases := []struct {
+		pattern string
+		value   string
+		matches bool
+	}{
+		{"logs/**/*.gz", "logs/a.gz", true},
+		{"logs/**/*.gz", "logs/2024/06/a.gz", true},
+		{"logs/**/*.gz", "other/logs/a.gz", false},
+		{"**/*.gz", "a.gz", true},
+		{"**/*.gz", "x/y/a.gz", true},
+		{"logs/**", "logs/x/y", true},
+		{"logs/**", "logsx", false},
+		{"**", "any/thing", true},
+		{"a/*/b", "a/x/y/b", false},
+	}
+
+	for _, c := range cases {
+		q, err := smartquery.GlobPathString(c.pattern)
+		assert.NilError(t, err, c.pattern)
+
+		matches, err := q.Matches(c.value)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, "%s against %s", c.pattern, c.value)
+	}
+}
+
+func TestInvalidGlob(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		globstar bool
+		err      string
+	}{
+		{"[abc", false, "unterminated character class"},
+		{"[]", false, "empty character class"},
+		{"[z-a]", false, "out of order"},
+		{"abc\\", false, "trailing backslash"},
+		{"a**b", true, "** must be a whole path segment"},
+		{"a/**b", true, "** must be a whole path segment"},
+	}
+
+	for _, c := range cases {
+		var err error
+		if c.globstar {
+			_, err = smartquery.GlobPathString(c.pattern)
+		} else {
+			_, err = smartquery.GlobString(c.pattern)
+		}
+		assert.ErrorContains(t, err, c.err, c.pattern)
+	}
+
+	// Outside of path mode ** is just two stars
+	q, err := smartquery.GlobString("a**b")
+	assert.NilError(t, err)
+	matches, err := q.Matches("axxb")
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "** in plain glob did not behave like *!")
+
+	// A glob built without the constructor reports its error when matched
+	bad := smartquery.NewStringQuery(smartquery.MatchGlob, optional.NewOption("[abc").AsRef())
+	_, err = bad.Matches("a")
+	assert.ErrorContains(t, err, "unterminated character class")
+
+	generic := smartquery.NewQuery(smartquery.MatchGlob, optional.NewOption(1).AsRef())
+	_, err = generic.Matches(1)
+	assert.ErrorContains(t, err, "cannot perform MatchGlob")
+}
@@ -4,6 +4,7 @@ go 1.23.1
 
 require (
 	github.com/brnsampson/optional v0.0.0-20240927223546-d3163da87963
+	golang.org/x/text v0.19.0
 	gotest.tools/v3 v3.5.1
 )
 
@@ -0,0 +1,39 @@
+package query
+
+import (
+	"github.com/brnsampson/optional"
+)
+
+// InQuery matches values which are equal to any one of a set of values. None is never a member of the set.
+type InQuery[T comparable] struct {
+	values []T
+	set    map[T]struct{}
+}
+
+func In[T comparable](values ...T) InQuery[T] {
+	set := make(map[T]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return InQuery[T]{values, set}
+}
+
+func (q InQuery[T]) AsRef() *InQuery[T] {
+	return &q
+}
+
+func (q *InQuery[T]) Values() []T {
+	return q.values
+}
+
+func (q *InQuery[T]) Matches(value T) (bool, error) {
+	_, ok := q.set[value]
+	return ok, nil
+}
+
+func (q *InQuery[T]) MatchesOption(value optional.Optional[T]) (bool, error) {
+	if value.IsNone() {
+		return false, nil
+	}
+	return q.Matches(value.UnsafeUnwrap())
+}
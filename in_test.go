@@ -0,0 +1,41 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestInQuery(t *testing.T) {
+	in := smartquery.In("a", "b", "c")
+	some := optional.NewOption("b")
+	other := optional.NewOption("z")
+	none := optional.None[string]()
+
+	matches, err := in.Matches("c")
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "In query did not match a member of the set!")
+
+	matches, err = in.Matches("z")
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "In query matched something outside of the set!")
+
+	matches, err = in.MatchesOption(&some)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "In query did not match option with a member of the set!")
+
+	matches, err = in.MatchesOption(&other)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "In query matched option with a value outside of the set!")
+
+	matches, err = in.MatchesOption(&none)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "In query matched option with None value!")
+
+	empty := smartquery.In[string]()
+	matches, err = empty.Matches("a")
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Empty In query should match nothing!")
+}
@@ -0,0 +1,298 @@
+package query
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/brnsampson/optional"
+)
+
+// Index is a secondary index over a single field of the records in a Collection. Indexes are created with HashIndex or
+// SortedIndex and handed to NewCollection, which keeps them up to date as records are inserted, updated and deleted.
+type Index[T comparable] interface {
+	// Field returns the name of the indexed field. Queries built with Field.Where on a field of the same name can be
+	// answered by the index.
+	Field() string
+
+	insert(id int, record T)
+	remove(id int, record T)
+	// lookup returns the ids of all records whose field matches the query in ascending order. ok is false when the index
+	// cannot answer the query and the caller has to fall back to evaluating it. The returned slice must not be modified.
+	lookup(query any) (ids []int, ok bool)
+}
+
+// hashIndex answers Exact and In queries with a map from field value to the ids which have that value. Ids whose
+// optional field is None are tracked in a separate bitmap so that None matches don't require a scan either.
+type hashIndex[T comparable, F comparable] struct {
+	field    Field[T, F]
+	postings map[F][]int
+	nones    Bitmap
+}
+
+func HashIndex[T comparable, F comparable](field Field[T, F]) Index[T] {
+	return &hashIndex[T, F]{field: field, postings: make(map[F][]int)}
+}
+
+func (h *hashIndex[T, F]) Field() string {
+	return h.field.name
+}
+
+func (h *hashIndex[T, F]) insert(id int, record T) {
+	value := h.field.Get(record)
+	if value.IsNone() {
+		h.nones.Set(id)
+		return
+	}
+	key := value.UnsafeUnwrap()
+	h.postings[key] = insertID(h.postings[key], id)
+}
+
+func (h *hashIndex[T, F]) remove(id int, record T) {
+	value := h.field.Get(record)
+	if value.IsNone() {
+		h.nones.Clear(id)
+		return
+	}
+	key := value.UnsafeUnwrap()
+	ids := removeID(h.postings[key], id)
+	if len(ids) == 0 {
+		delete(h.postings, key)
+	} else {
+		h.postings[key] = ids
+	}
+}
+
+func (h *hashIndex[T, F]) lookup(query any) ([]int, bool) {
+	switch q := query.(type) {
+	case *FieldQuery[F]:
+		return h.lookupCriteria(q.criteria, q.value)
+	case *StringQuery:
+		// Only usable when the indexed field is itself a string. Keys are stored as is, so normalized queries need a scan.
+		if value, ok := any(q.value).(optional.Optional[F]); ok && q.normalization == 0 {
+			return h.lookupCriteria(q.criteria, value)
+		}
+	case *InQuery[F]:
+		lists := make([][]int, 0, len(q.set))
+		for v := range q.set {
+			lists = append(lists, h.postings[v])
+		}
+		return unionIDs(lists...), true
+	}
+	return nil, false
+}
+
+func (h *hashIndex[T, F]) lookupCriteria(c MatchType, value optional.Optional[F]) ([]int, bool) {
+	if c == MatchExact {
+		if value.IsNone() {
+			// An Exact match of None only matches None, which is impossible for a plain field
+			return h.noneIDs(), true
+		}
+		return h.postings[value.UnsafeUnwrap()], true
+	} else if c == MatchNone {
+		return h.noneIDs(), true
+	}
+	return nil, false
+}
+
+func (h *hashIndex[T, F]) noneIDs() []int {
+	if !h.field.IsOption() {
+		return nil
+	}
+	return h.nones.Indices()
+}
+
+type sortedEntry[F cmp.Ordered] struct {
+	key F
+	id  int
+}
+
+// sortedIndex answers range queries (as well as Exact and In queries) by keeping (value, id) pairs sorted in a B-tree so
+// matches can be found without a scan and records can be added and removed in O(log n). Like hashIndex, None values
+// are tracked in a bitmap.
+type sortedIndex[T comparable, F cmp.Ordered] struct {
+	field   Field[T, F]
+	entries *btree[sortedEntry[F]]
+	nones   Bitmap
+}
+
+func SortedIndex[T comparable, F cmp.Ordered](field Field[T, F]) Index[T] {
+	return &sortedIndex[T, F]{field: field, entries: newBtree(compareEntries[F])}
+}
+
+func compareEntries[F cmp.Ordered](a, b sortedEntry[F]) int {
+	if c := cmp.Compare(a.key, b.key); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.id, b.id)
+}
+
+func (s *sortedIndex[T, F]) Field() string {
+	return s.field.name
+}
+
+func (s *sortedIndex[T, F]) insert(id int, record T) {
+	value := s.field.Get(record)
+	if value.IsNone() {
+		s.nones.Set(id)
+		return
+	}
+	s.entries.insert(sortedEntry[F]{value.UnsafeUnwrap(), id})
+}
+
+func (s *sortedIndex[T, F]) remove(id int, record T) {
+	value := s.field.Get(record)
+	if value.IsNone() {
+		s.nones.Clear(id)
+		return
+	}
+	s.entries.remove(sortedEntry[F]{value.UnsafeUnwrap(), id})
+}
+
+func (s *sortedIndex[T, F]) lookup(query any) ([]int, bool) {
+	switch q := query.(type) {
+	case *RangeQuery[F]:
+		if q.nans != NaNUnordered {
+			// The index is sorted with cmp.Compare, which doesn't agree with NaNLargest. Leave these to a scan.
+			return nil, false
+		}
+		// Entries are ordered by key and then id, so the first entry for a key has the smallest possible id and the last
+		// the largest
+		var from *sortedEntry[F]
+		if !q.lower.IsNone() {
+			bound := q.lower.UnsafeUnwrap()
+			if bound != bound {
+				// NaN bounds never match anything
+				return nil, true
+			}
+			if q.lowerInclusive {
+				from = &sortedEntry[F]{bound, math.MinInt}
+			} else {
+				from = &sortedEntry[F]{bound, math.MaxInt}
+			}
+		}
+		inRange := func(key F) bool { return true }
+		if !q.upper.IsNone() {
+			bound := q.upper.UnsafeUnwrap()
+			if bound != bound {
+				return nil, true
+			}
+			if q.upperInclusive {
+				inRange = func(key F) bool { return cmp.Compare(key, bound) <= 0 }
+			} else {
+				inRange = func(key F) bool { return cmp.Compare(key, bound) < 0 }
+			}
+		}
+		ids := []int{}
+		s.entries.ascend(from, func(e sortedEntry[F]) bool {
+			if !inRange(e.key) {
+				return false
+			}
+			// cmp.Compare sorts NaN first, but NaN is never inside of a range
+			if e.key == e.key {
+				ids = append(ids, e.id)
+			}
+			return true
+		})
+		slices.Sort(ids)
+		return ids, true
+	case *FieldQuery[F]:
+		return s.lookupCriteria(q.criteria, q.value)
+	case *StringQuery:
+		if value, ok := any(q.value).(optional.Optional[F]); ok && q.normalization == 0 {
+			return s.lookupCriteria(q.criteria, value)
+		}
+	case *InQuery[F]:
+		lists := make([][]int, 0, len(q.set))
+		for v := range q.set {
+			lists = append(lists, s.equal(v))
+		}
+		return unionIDs(lists...), true
+	}
+	return nil, false
+}
+
+func (s *sortedIndex[T, F]) lookupCriteria(c MatchType, value optional.Optional[F]) ([]int, bool) {
+	if c == MatchExact {
+		if value.IsNone() {
+			return s.noneIDs(), true
+		}
+		return s.equal(value.UnsafeUnwrap()), true
+	} else if c == MatchNone {
+		return s.noneIDs(), true
+	}
+	return nil, false
+}
+
+// equal returns the ids with exactly the given key. Entries with the same key are already sorted by id.
+func (s *sortedIndex[T, F]) equal(key F) []int {
+	ids := []int{}
+	s.entries.ascend(&sortedEntry[F]{key, math.MinInt}, func(e sortedEntry[F]) bool {
+		if e.key != key {
+			return false
+		}
+		ids = append(ids, e.id)
+		return true
+	})
+	return ids
+}
+
+func (s *sortedIndex[T, F]) noneIDs() []int {
+	if !s.field.IsOption() {
+		return nil
+	}
+	return s.nones.Indices()
+}
+
+// insertID adds id to a sorted list of ids. Ids are usually handed out in increasing order, so appending is the fast
+// path.
+func insertID(ids []int, id int) []int {
+	if len(ids) == 0 || ids[len(ids)-1] < id {
+		return append(ids, id)
+	}
+	i, found := slices.BinarySearch(ids, id)
+	if found {
+		return ids
+	}
+	return slices.Insert(ids, i, id)
+}
+
+func removeID(ids []int, id int) []int {
+	i, found := slices.BinarySearch(ids, id)
+	if !found {
+		return ids
+	}
+	return slices.Delete(ids, i, i+1)
+}
+
+// intersectIDs returns the ids present in both sorted lists.
+func intersectIDs(a, b []int) []int {
+	out := make([]int, 0, min(len(a), len(b)))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] < b[j] {
+			i++
+		} else if a[i] > b[j] {
+			j++
+		} else {
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// unionIDs merges any number of sorted lists of ids into a single sorted list without duplicates.
+func unionIDs(lists ...[]int) []int {
+	total := 0
+	for _, l := range lists {
+		total += len(l)
+	}
+	out := make([]int, 0, total)
+	for _, l := range lists {
+		out = append(out, l...)
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
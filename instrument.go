@@ -0,0 +1,226 @@
+package query
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/brnsampson/optional"
+)
+
+// Observer receives a call for every evaluation of every node of an instrumented query, with the label of the node,
+// its result and how long it took. Implement it to export the numbers to a metrics system; RuntimeStats keeps them in
+// memory. Observers are called from whichever goroutine evaluates the query, so they must be safe for concurrent use.
+type Observer interface {
+	Observe(label string, matched bool, err error, elapsed time.Duration)
+}
+
+// Instrument returns a copy of the query which reports every evaluation of every node to the observer. The root is
+// labelled name. The children of And, Or and Not are labelled by their position under their parent, with the name of
+// the field for queries made with Field.Where, so the second child of an And on the stars field is "name/1:stars".
+// Predicates are labelled with their name the same way, as in "name/2:chester()".
+// Field predicates, references and other queries are not looked into, which keeps labels stable when a referenced
+// query is redefined.
+//
+// Labels belong to the nodes rather than their positions, so they don't change when the query is passed to Optimize.
+// Instrumented queries can't use the indexes of a Collection, and timing every node has a cost of its own, so only
+// instrument queries you are investigating.
+func Instrument[T comparable](name string, query Query[T], observer Observer) Query[T] {
+	return instrument(name, query, observer)
+}
+
+func instrument[T comparable](label string, query Query[T], observer Observer) Query[T] {
+	switch q := query.(type) {
+	case *AndQuery[T]:
+		query = And(instrumentChildren(label, q.children, observer)...).AsRef()
+	case *OrQuery[T]:
+		query = Or(instrumentChildren(label, q.children, observer)...).AsRef()
+	case *NotQuery[T]:
+		query = Not(instrumentChild(label, 0, q.child, observer)).AsRef()
+	}
+	return &instrumentedQuery[T]{query, label, observer}
+}
+
+func instrumentChildren[T comparable](label string, children []Query[T], observer Observer) []Query[T] {
+	out := make([]Query[T], len(children))
+	for i, child := range children {
+		out[i] = instrumentChild(label, i, child, observer)
+	}
+	return out
+}
+
+func instrumentChild[T comparable](label string, i int, child Query[T], observer Observer) Query[T] {
+	label += "/" + strconv.Itoa(i)
+	if p, ok := child.(fieldPredicate); ok {
+		label += ":" + p.fieldName()
+	} else if p, ok := child.(*PredicateQuery[T]); ok {
+		label += ":" + p.String()
+	}
+	return instrument(label, child, observer)
+}
+
+type instrumentedQuery[T comparable] struct {
+	query    Query[T]
+	label    string
+	observer Observer
+}
+
+func (q *instrumentedQuery[T]) Matches(value T) (bool, error) {
+	return q.MatchesContext(nil, value)
+}
+
+func (q *instrumentedQuery[T]) MatchesOption(value optional.Optional[T]) (bool, error) {
+	return q.MatchesOptionContext(nil, value)
+}
+
+func (q *instrumentedQuery[T]) MatchesContext(ctx *EvalContext, value T) (bool, error) {
+	start := time.Now()
+	matched, err := Evaluate(ctx, q.query, value)
+	q.observer.Observe(q.label, matched, err, time.Since(start))
+	return matched, err
+}
+
+func (q *instrumentedQuery[T]) MatchesOptionContext(ctx *EvalContext, value optional.Optional[T]) (bool, error) {
+	start := time.Now()
+	matched, err := EvaluateOption(ctx, q.query, value)
+	q.observer.Observe(q.label, matched, err, time.Since(start))
+	return matched, err
+}
+
+func (q *instrumentedQuery[T]) observedBy() (Observer, string) {
+	return q.observer, q.label
+}
+
+// wrap returns an instrumented copy of a rebuilt query, or q itself if nothing changed. It lets the methods below pass
+// everything else which walks the query tree through to the wrapped query.
+func (q *instrumentedQuery[T]) wrap(query Query[T]) Query[T] {
+	if query == q.query {
+		return q
+	}
+	return &instrumentedQuery[T]{query, q.label, q.observer}
+}
+
+func (q *instrumentedQuery[T]) addDependencies(d *dependencies) {
+	addDependencies(d, q.query)
+}
+
+func (q *instrumentedQuery[T]) bind(b *binder) (Query[T], bool) {
+	query, changed := bindQuery(b, q.query)
+	return q.wrap(query), changed
+}
+
+func (q *instrumentedQuery[T]) optimize(o *optimizer) Query[T] {
+	return q.wrap(optimizeQuery(o, q.query))
+}
+
+func (q *instrumentedQuery[T]) estimateCost() float64 {
+	return costOf(q.query)
+}
+
+func (q *instrumentedQuery[T]) estimateSelectivity(o *optimizer) float64 {
+	return o.selectivity(q.query)
+}
+
+// observed is implemented by instrumented queries of any type.
+type observed interface {
+	observedBy() (Observer, string)
+}
+
+// NodeStats is what RuntimeStats has recorded about one node of a query.
+type NodeStats struct {
+	Evaluations int
+	Matches     int
+	Errors      int
+	Time        time.Duration
+}
+
+// MatchRate returns the fraction of evaluations which matched, or 0 if there were none.
+func (s NodeStats) MatchRate() float64 {
+	if s.Evaluations == 0 {
+		return 0
+	}
+	return float64(s.Matches) / float64(s.Evaluations)
+}
+
+func (s NodeStats) MeanTime() time.Duration {
+	if s.Evaluations == 0 {
+		return 0
+	}
+	return s.Time / time.Duration(s.Evaluations)
+}
+
+// minSamples is how many evaluations RuntimeStats wants to have seen before it reports a selectivity to Optimize.
+const minSamples = 100
+
+// RuntimeStats is an Observer which keeps running totals in memory. It is also a Statistics for Optimize, giving the
+// observed match rate of the nodes it has seen evaluated at least 100 times, so a query can be instrumented, run for a
+// while and then optimized for the data it actually sees.
+type RuntimeStats struct {
+	lock  sync.Mutex
+	nodes map[string]NodeStats
+}
+
+func NewRuntimeStats() *RuntimeStats {
+	return &RuntimeStats{nodes: make(map[string]NodeStats)}
+}
+
+func (s *RuntimeStats) Observe(label string, matched bool, err error, elapsed time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n := s.nodes[label]
+	n.Evaluations++
+	if matched {
+		n.Matches++
+	}
+	if err != nil {
+		n.Errors++
+	}
+	n.Time += elapsed
+	s.nodes[label] = n
+}
+
+func (s *RuntimeStats) Get(label string) (NodeStats, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n, ok := s.nodes[label]
+	return n, ok
+}
+
+// Labels returns the labels of every node which has been evaluated, in sorted order.
+func (s *RuntimeStats) Labels() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	labels := make([]string, 0, len(s.nodes))
+	for label := range s.nodes {
+		labels = append(labels, label)
+	}
+	slices.Sort(labels)
+	return labels
+}
+
+func (s *RuntimeStats) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	clear(s.nodes)
+}
+
+func (s *RuntimeStats) Selectivity(query any) (float64, bool) {
+	q, ok := query.(observed)
+	if !ok {
+		return 0, false
+	}
+	observer, label := q.observedBy()
+	if observer != Observer(s) {
+		return 0, false
+	}
+	n, ok := s.Get(label)
+	if !ok || n.Evaluations < minSamples {
+		return 0, false
+	}
+	return n.MatchRate(), true
+}
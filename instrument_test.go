@@ -0,0 +1,112 @@
+package query_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestInstrument(t *testing.T) {
+	stats := smartquery.NewRuntimeStats()
+	q := smartquery.Instrument[testStruct]("vip", smartquery.And[testStruct](
+		balanceField.Where(smartquery.AtLeast(100).AsRef()).AsRef(),
+		smartquery.Not[testStruct](starsField.Where(smartquery.Exact(0).AsRef()).AsRef()).AsRef(),
+	).AsRef(), stats)
+
+	records := []testStruct{
+		{Name: "a", Email: optional.None[string]().AsRef(), Balance: 50, Stars: optional.NewOption(1).AsRef()},
+		{Name: "b", Email: optional.None[string]().AsRef(), Balance: 150, Stars: optional.NewOption(0).AsRef()},
+		{Name: "c", Email: optional.None[string]().AsRef(), Balance: 250, Stars: optional.NewOption(3).AsRef()},
+	}
+	matches := 0
+	for _, r := range records {
+		matched, err := q.Matches(r)
+		assert.NilError(t, err)
+		if matched {
+			matches++
+		}
+	}
+	assert.Equal(t, matches, 1)
+
+	assert.DeepEqual(t, stats.Labels(), []string{"vip", "vip/0:balance", "vip/1", "vip/1/0:stars"})
+	root, ok := stats.Get("vip")
+	assert.Assert(t, ok)
+	assert.Equal(t, root.Evaluations, 3)
+	assert.Equal(t, root.Matches, 1)
+
+	// The And stopped at the balance check for the first record
+	balance, _ := stats.Get("vip/0:balance")
+	assert.Equal(t, balance.Evaluations, 3)
+	assert.Equal(t, balance.MatchRate(), 2.0/3)
+	stars, _ := stats.Get("vip/1/0:stars")
+	assert.Equal(t, stars.Evaluations, 2)
+	assert.Equal(t, stars.Matches, 1)
+
+	// Dependencies see through the instrumentation
+	fields, complete := smartquery.Dependencies(q)
+	assert.Assert(t, complete)
+	assert.DeepEqual(t, fields, []string{"balance", "stars"})
+
+	stats.Reset()
+	assert.Equal(t, len(stats.Labels()), 0)
+}
+
+func TestInstrumentPredicateLabels(t *testing.T) {
+	stats := smartquery.NewRuntimeStats()
+	chester := smartquery.Predicate("chester", func(s testStruct) (bool, error) { return s.Name == "Chester", nil })
+	q := smartquery.Instrument[testStruct]("q", smartquery.Or[testStruct](
+		balanceField.Where(smartquery.Exact(1).AsRef()).AsRef(),
+		chester.AsRef(),
+	).AsRef(), stats)
+
+	_, err := q.Matches(testStruct{Name: "Chester", Email: optional.None[string]().AsRef(), Stars: optional.None[int]().AsRef()})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, stats.Labels(), []string{"q", "q/0:balance", "q/1:chester()"})
+}
+
+func TestInstrumentErrors(t *testing.T) {
+	stats := smartquery.NewRuntimeStats()
+	failing := smartquery.Predicate("failing", func(v int) (bool, error) { return false, errors.New("boom") })
+	q := smartquery.Instrument[int]("f", smartquery.Or[int](smartquery.Exact(1).AsRef(), failing.AsRef()).AsRef(), stats)
+
+	matched, err := q.Matches(1)
+	assert.NilError(t, err)
+	assert.Assert(t, matched)
+	_, err = q.MatchesOption(optional.NewOption(2).AsRef())
+	assert.ErrorContains(t, err, "boom")
+
+	root, _ := stats.Get("f")
+	assert.Equal(t, root.Evaluations, 2)
+	assert.Equal(t, root.Errors, 1)
+	predicate, _ := stats.Get("f/1:failing()")
+	assert.Equal(t, predicate, smartquery.NodeStats{Evaluations: 1, Errors: 1, Time: predicate.Time})
+}
+
+func TestInstrumentOptimize(t *testing.T) {
+	stats := smartquery.NewRuntimeStats()
+	// Both children cost the same, and without statistics the order is left alone
+	q := smartquery.Instrument[int]("q", smartquery.And[int](
+		smartquery.AtLeast(0).AsRef(),
+		smartquery.Exact(7).AsRef(),
+	).AsRef(), stats)
+	for i := range 200 {
+		_, err := q.Matches(i)
+		assert.NilError(t, err)
+	}
+	atLeast, _ := stats.Get("q/0")
+	assert.Equal(t, atLeast.MatchRate(), 1.0)
+
+	optimized := smartquery.Optimize(q, stats)
+	for i := range 200 {
+		_, err := optimized.Matches(i)
+		assert.NilError(t, err)
+	}
+	// Exact rarely matches so it now runs first, and AtLeast only sees the one value that got past it
+	atLeast, _ = stats.Get("q/0")
+	assert.Equal(t, atLeast.Evaluations, 201)
+	exact, _ := stats.Get("q/1")
+	assert.Equal(t, exact.Evaluations, 400)
+}
@@ -0,0 +1,250 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brnsampson/optional"
+)
+
+type mapRequirementKind int
+
+const (
+	requireKey mapRequirementKind = iota
+	requireNoKey
+	requireValue
+)
+
+type mapRequirement[K comparable, V comparable] struct {
+	kind  mapRequirementKind
+	key   K
+	query Query[V]
+}
+
+// MapQuery matches map values, such as Kubernetes style labels, by requiring keys to be present or absent and by
+// applying queries to the values of keys. A MapQuery matches when all of its requirements are met.
+//
+// Maps are not comparable, so a MapQuery cannot be a Query[map[K]V]. Use MapField to apply one to a map valued field of
+// a record instead.
+type MapQuery[K comparable, V comparable] struct {
+	requirements []mapRequirement[K, V]
+}
+
+func HasKey[K comparable, V comparable](key K) MapQuery[K, V] {
+	return MapQuery[K, V]{[]mapRequirement[K, V]{{kind: requireKey, key: key}}}
+}
+
+func LacksKey[K comparable, V comparable](key K) MapQuery[K, V] {
+	return MapQuery[K, V]{[]mapRequirement[K, V]{{kind: requireNoKey, key: key}}}
+}
+
+// KeyMatches applies a query to the value of a key. A missing key is None, so for example KeyMatches(k, Not(Exact(v)))
+// matches maps which don't have k at all.
+func KeyMatches[K comparable, V comparable](key K, query Query[V]) MapQuery[K, V] {
+	return MapQuery[K, V]{[]mapRequirement[K, V]{{kind: requireValue, key: key, query: query}}}
+}
+
+// And returns a MapQuery with the requirements of q and all of the others.
+func (q MapQuery[K, V]) And(others ...MapQuery[K, V]) MapQuery[K, V] {
+	requirements := append([]mapRequirement[K, V]{}, q.requirements...)
+	for _, o := range others {
+		requirements = append(requirements, o.requirements...)
+	}
+	return MapQuery[K, V]{requirements}
+}
+
+func (q MapQuery[K, V]) Matches(m map[K]V) (bool, error) {
+	return q.MatchesContext(nil, m)
+}
+
+func (q MapQuery[K, V]) MatchesContext(ctx *EvalContext, m map[K]V) (bool, error) {
+	for _, r := range q.requirements {
+		value, ok := m[r.key]
+		if r.kind == requireKey && !ok {
+			return false, nil
+		} else if r.kind == requireNoKey && ok {
+			return false, nil
+		} else if r.kind == requireValue {
+			var matched bool
+			var err error
+			if ok {
+				matched, err = Evaluate(ctx, r.query, value)
+			} else {
+				matched, err = EvaluateOption(ctx, r.query, optional.None[V]().AsRef())
+			}
+			if err != nil {
+				return false, err
+			}
+			if !matched {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// Selector compiles a Kubernetes style label selector such as "env=prod,tier!=web,region in (a,b)" into a MapQuery.
+// Supported requirements are key=value, key==value, key!=value, key in (v1,v2), key notin (v1,v2), key and !key. Like
+// Kubernetes, != and notin also match maps which do not have the key.
+func Selector(selector string) (MapQuery[string, string], error) {
+	var q MapQuery[string, string]
+	for _, requirement := range splitSelector(selector) {
+		requirement = strings.TrimSpace(requirement)
+		if requirement == "" {
+			continue
+		}
+		r, err := parseRequirement(requirement)
+		if err != nil {
+			return MapQuery[string, string]{}, fmt.Errorf("QueryError: invalid selector %q: %w", selector, err)
+		}
+		q = q.And(r)
+	}
+	return q, nil
+}
+
+// splitSelector splits a selector on the commas which are not inside of a set of values.
+func splitSelector(selector string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, selector[start:])
+}
+
+func parseRequirement(requirement string) (MapQuery[string, string], error) {
+	if fields := strings.Fields(requirement); len(fields) >= 2 && (fields[1] == "in" || fields[1] == "notin" ||
+		strings.HasPrefix(fields[1], "in(") || strings.HasPrefix(fields[1], "notin(")) {
+		return parseSetRequirement(requirement)
+	}
+
+	for _, op := range []string{"!=", "==", "="} {
+		key, value, found := strings.Cut(requirement, op)
+		if !found {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if err := validateSelectorToken(key, "key"); err != nil {
+			return MapQuery[string, string]{}, err
+		}
+		if strings.ContainsAny(value, "=!() ") {
+			return MapQuery[string, string]{}, fmt.Errorf("invalid value %q", value)
+		}
+		exact := ExactString(value)
+		if op == "!=" {
+			return KeyMatches[string](key, Not[string](exact.AsRef()).AsRef()), nil
+		}
+		return KeyMatches[string](key, exact.AsRef()), nil
+	}
+
+	if key, found := strings.CutPrefix(requirement, "!"); found {
+		key = strings.TrimSpace(key)
+		if err := validateSelectorToken(key, "key"); err != nil {
+			return MapQuery[string, string]{}, err
+		}
+		return LacksKey[string, string](key), nil
+	}
+	if err := validateSelectorToken(requirement, "key"); err != nil {
+		return MapQuery[string, string]{}, err
+	}
+	return HasKey[string, string](requirement), nil
+}
+
+// parseSetRequirement handles "key in (a,b)" and "key notin (a,b)". The set is compiled to an Or of ExactString
+// queries.
+func parseSetRequirement(requirement string) (MapQuery[string, string], error) {
+	open := strings.Index(requirement, "(")
+	if open < 0 || !strings.HasSuffix(requirement, ")") {
+		return MapQuery[string, string]{}, fmt.Errorf("set of values in %q must be in parentheses", requirement)
+	}
+	head := strings.Fields(requirement[:open])
+	if len(head) != 2 {
+		return MapQuery[string, string]{}, fmt.Errorf("invalid set requirement %q", requirement)
+	}
+	key, op := head[0], head[1]
+	if err := validateSelectorToken(key, "key"); err != nil {
+		return MapQuery[string, string]{}, err
+	}
+
+	var values []Query[string]
+	for _, v := range strings.Split(requirement[open+1:len(requirement)-1], ",") {
+		v = strings.TrimSpace(v)
+		if err := validateSelectorToken(v, "value"); err != nil {
+			return MapQuery[string, string]{}, err
+		}
+		values = append(values, ExactString(v).AsRef())
+	}
+
+	set := Or(values...)
+	if op == "notin" {
+		return KeyMatches[string](key, Not[string](set.AsRef()).AsRef()), nil
+	}
+	return KeyMatches[string](key, set.AsRef()), nil
+}
+
+func validateSelectorToken(token, what string) error {
+	if token == "" {
+		return fmt.Errorf("empty %s", what)
+	}
+	if strings.ContainsAny(token, "=!(), ") {
+		return fmt.Errorf("invalid %s %q", what, token)
+	}
+	return nil
+}
+
+// MapField is a map valued field of a record. Maps are not comparable, so they cannot be used with Field.
+type MapField[T comparable, K comparable, V comparable] struct {
+	name  string
+	value func(T) map[K]V
+}
+
+func NewMapField[T comparable, K comparable, V comparable](name string, value func(T) map[K]V) MapField[T, K, V] {
+	return MapField[T, K, V]{name, value}
+}
+
+func (f MapField[T, K, V]) Name() string {
+	return f.name
+}
+
+// Where creates a query which matches records whose map field matches the given MapQuery.
+func (f MapField[T, K, V]) Where(query MapQuery[K, V]) MapPredicate[T, K, V] {
+	return MapPredicate[T, K, V]{f, query}
+}
+
+type MapPredicate[T comparable, K comparable, V comparable] struct {
+	field MapField[T, K, V]
+	query MapQuery[K, V]
+}
+
+func (p MapPredicate[T, K, V]) AsRef() *MapPredicate[T, K, V] {
+	return &p
+}
+
+func (p *MapPredicate[T, K, V]) Matches(record T) (bool, error) {
+	return p.MatchesContext(nil, record)
+}
+
+func (p *MapPredicate[T, K, V]) MatchesOption(record optional.Optional[T]) (bool, error) {
+	return p.MatchesOptionContext(nil, record)
+}
+
+func (p *MapPredicate[T, K, V]) MatchesContext(ctx *EvalContext, record T) (bool, error) {
+	return p.query.MatchesContext(ctx, p.field.value(record))
+}
+
+func (p *MapPredicate[T, K, V]) MatchesOptionContext(ctx *EvalContext, record optional.Optional[T]) (bool, error) {
+	if record.IsNone() {
+		return false, nil
+	}
+	return p.MatchesContext(ctx, record.UnsafeUnwrap())
+}
@@ -0,0 +1,101 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestMapQuery(t *testing.T) {
+	labels := map[string]string{"env": "prod", "tier": "db"}
+
+	cases := []struct {
+		name    string
+		query   smartquery.MapQuery[string, string]
+		matches bool
+	}{
+		{"has key", smartquery.HasKey[string, string]("env"), true},
+		{"has missing key", smartquery.HasKey[string, string]("region"), false},
+		{"lacks key", smartquery.LacksKey[string, string]("region"), true},
+		{"lacks present key", smartquery.LacksKey[string, string]("env"), false},
+		{"key matches", smartquery.KeyMatches[string]("env", smartquery.LikeString("pr%").AsRef()), true},
+		{"key does not match", smartquery.KeyMatches[string]("tier", smartquery.ExactString("web").AsRef()), false},
+		{"missing key is none", smartquery.KeyMatches[string]("region", smartquery.NoneString("").AsRef()), true},
+		{"missing key exact", smartquery.KeyMatches[string]("region", smartquery.ExactString("").AsRef()), false},
+		{"and", smartquery.HasKey[string, string]("env").And(smartquery.LacksKey[string, string]("tier")), false},
+	}
+
+	for _, c := range cases {
+		matches, err := c.query.Matches(labels)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, c.name)
+	}
+
+	broken := smartquery.KeyMatches[string]("count", smartquery.Like(1).AsRef())
+	_, err := broken.Matches(map[string]int{"count": 1})
+	assert.ErrorContains(t, err, "QueryError")
+}
+
+func TestSelector(t *testing.T) {
+	cases := []struct {
+		selector string
+		labels   map[string]string
+		matches  bool
+	}{
+		{"env=prod", map[string]string{"env": "prod"}, true},
+		{"env==prod", map[string]string{"env": "dev"}, false},
+		{"env=prod,tier!=web", map[string]string{"env": "prod", "tier": "db"}, true},
+		{"env=prod,tier!=web", map[string]string{"env": "prod", "tier": "web"}, false},
+		{"tier!=web", map[string]string{}, true},
+		{"region in (a, b)", map[string]string{"region": "b"}, true},
+		{"region in (a,b)", map[string]string{"region": "c"}, false},
+		{"region in (a,b)", map[string]string{}, false},
+		{"region notin (a,b)", map[string]string{"region": "c"}, true},
+		{"region notin (a,b)", map[string]string{}, true},
+		{"env", map[string]string{"env": ""}, true},
+		{"!env", map[string]string{"env": ""}, false},
+		{" env = prod , region in (a,b), !debug ", map[string]string{"env": "prod", "region": "a"}, true},
+		{"", map[string]string{"env": "prod"}, true},
+	}
+
+	for _, c := range cases {
+		q, err := smartquery.Selector(c.selector)
+		assert.NilError(t, err, c.selector)
+		matches, err := q.Matches(c.labels)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, "%q against %v", c.selector, c.labels)
+	}
+
+	for _, invalid := range []string{"=prod", "env=prod=x", "region in a,b", "region in (a,,b)", "a b", "!", "region within (a)"} {
+		_, err := smartquery.Selector(invalid)
+		assert.ErrorContains(t, err, "QueryError", invalid)
+	}
+}
+
+type labeledStruct struct {
+	Name   string
+	Labels *map[string]string
+}
+
+func TestMapField(t *testing.T) {
+	labelsField := smartquery.NewMapField("labels", func(s labeledStruct) map[string]string { return *s.Labels })
+	selector, err := smartquery.Selector("env=prod")
+	assert.NilError(t, err)
+	q := labelsField.Where(selector)
+
+	prod := labeledStruct{"a", &map[string]string{"env": "prod"}}
+	dev := labeledStruct{"b", &map[string]string{"env": "dev"}}
+
+	found, err := smartquery.Filter([]labeledStruct{prod, dev}, q.AsRef())
+	assert.NilError(t, err)
+	assert.Equal(t, len(found), 1)
+	assert.Equal(t, found[0].Name, "a")
+
+	none := optional.None[labeledStruct]()
+	matches, err := q.MatchesOption(&none)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Map predicate matched a None record!")
+	assert.Equal(t, labelsField.Name(), "labels")
+}
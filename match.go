@@ -39,3 +39,24 @@ func MatchAll(matches []Matcher) (bool, error) {
 	}
 	return true, nil
 }
+
+// Filter returns the records which match the query, in their original order. This is a linear scan; see Collection if
+// you need to look records up by indexed fields.
+func Filter[T comparable](records []T, query Query[T]) ([]T, error) {
+	return FilterContext(nil, records, query)
+}
+
+// FilterContext is Filter with the query evaluated in the given context.
+func FilterContext[T comparable](ctx *EvalContext, records []T, query Query[T]) ([]T, error) {
+	out := make([]T, 0)
+	for _, r := range records {
+		matched, err := Evaluate(ctx, query, r)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
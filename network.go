@@ -0,0 +1,290 @@
+package query
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/brnsampson/optional"
+)
+
+type AddrMatchType int
+
+const (
+	// IP address matching operations. IPv4-mapped IPv6 addresses (::ffff:a.b.c.d) are unmapped before matching, so they
+	// are treated as the IPv4 address they hold.
+	MatchInPrefixes AddrMatchType = iota // True if the address is inside of any of a set of prefixes
+	MatchPrivate                         // True if the address is in a private range (RFC 1918 or RFC 4193)
+	MatchLoopback                        // True if the address is a loopback address
+	MatchFamily                          // True if the address is in the same family (IPv4 or IPv6) as another address
+)
+
+// AddrQuery matches netip.Addr values. The zero Addr is not a valid address and never matches, and neither does None.
+type AddrQuery struct {
+	criteria AddrMatchType
+	prefixes *PrefixSet
+	family   netip.Addr
+	err      error
+}
+
+// InCIDR matches addresses inside of any of the prefixes. The prefixes are stored in a trie, so the cost of a match
+// depends on the length of the address rather than on the number of prefixes. An invalid prefix is returned as an
+// error from every match, the same as an invalid PathQuery.
+func InCIDR(prefixes ...netip.Prefix) AddrQuery {
+	set, err := NewPrefixSet(prefixes...)
+	return AddrQuery{criteria: MatchInPrefixes, prefixes: set, err: err}
+}
+
+// ParseCIDR is InCIDR for prefixes in string form such as "10.0.0.0/8".
+func ParseCIDR(prefixes ...string) (AddrQuery, error) {
+	parsed := make([]netip.Prefix, len(prefixes))
+	for i, p := range prefixes {
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return AddrQuery{}, fmt.Errorf("QueryError: %w", err)
+		}
+		parsed[i] = prefix
+	}
+	q := InCIDR(parsed...)
+	return q, q.err
+}
+
+func IsPrivate() AddrQuery {
+	return AddrQuery{criteria: MatchPrivate}
+}
+
+func IsLoopback() AddrQuery {
+	return AddrQuery{criteria: MatchLoopback}
+}
+
+// SameFamily matches addresses in the same family as addr: IPv4 if addr is an IPv4 or IPv4-mapped address and IPv6
+// otherwise.
+func SameFamily(addr netip.Addr) AddrQuery {
+	q := AddrQuery{criteria: MatchFamily, family: addr.Unmap()}
+	if !addr.IsValid() {
+		q.err = fmt.Errorf("QueryError: invalid address for family match")
+	}
+	return q
+}
+
+func (q AddrQuery) AsRef() *AddrQuery {
+	return &q
+}
+
+func (q *AddrQuery) Err() error {
+	return q.err
+}
+
+func (q *AddrQuery) Matches(value netip.Addr) (bool, error) {
+	if q.err != nil {
+		return false, q.err
+	}
+	if !value.IsValid() {
+		return false, nil
+	}
+	value = value.Unmap()
+
+	c := q.criteria
+	if c == MatchInPrefixes {
+		return q.prefixes.Contains(value), nil
+	} else if c == MatchPrivate {
+		return value.IsPrivate(), nil
+	} else if c == MatchLoopback {
+		return value.IsLoopback(), nil
+	} else if c == MatchFamily {
+		return value.Is4() == q.family.Is4(), nil
+	}
+	return false, fmt.Errorf("QueryError: unsupported address matching strategy: %d", c)
+}
+
+func (q *AddrQuery) MatchesOption(value optional.Optional[netip.Addr]) (bool, error) {
+	if value.IsNone() {
+		return false, nil
+	}
+	return q.Matches(value.UnsafeUnwrap())
+}
+
+type PrefixMatchType int
+
+const (
+	// Prefix matching operations
+	MatchPrefixWithin   PrefixMatchType = iota // True if the prefix is inside of any of a set of prefixes
+	MatchPrefixContains                        // True if the prefix contains an address
+	MatchPrefixOverlaps                        // True if the prefix and another prefix have any addresses in common
+)
+
+// PrefixQuery matches netip.Prefix values, for records which describe networks rather than hosts. Invalid prefixes
+// and None never match.
+type PrefixQuery struct {
+	criteria PrefixMatchType
+	prefixes *PrefixSet
+	addr     netip.Addr
+	prefix   netip.Prefix
+	err      error
+}
+
+// PrefixWithin matches prefixes which are equal to or more specific than any of the given prefixes, so
+// PrefixWithin(10.0.0.0/8) matches 10.1.0.0/16 but not 0.0.0.0/0.
+func PrefixWithin(prefixes ...netip.Prefix) PrefixQuery {
+	set, err := NewPrefixSet(prefixes...)
+	return PrefixQuery{criteria: MatchPrefixWithin, prefixes: set, err: err}
+}
+
+func PrefixContains(addr netip.Addr) PrefixQuery {
+	q := PrefixQuery{criteria: MatchPrefixContains, addr: addr.Unmap()}
+	if !addr.IsValid() {
+		q.err = fmt.Errorf("QueryError: invalid address for prefix match")
+	}
+	return q
+}
+
+func PrefixOverlaps(prefix netip.Prefix) PrefixQuery {
+	q := PrefixQuery{criteria: MatchPrefixOverlaps, prefix: prefix.Masked()}
+	if !prefix.IsValid() {
+		q.err = fmt.Errorf("QueryError: invalid prefix %s", prefix)
+	}
+	return q
+}
+
+func (q PrefixQuery) AsRef() *PrefixQuery {
+	return &q
+}
+
+func (q *PrefixQuery) Err() error {
+	return q.err
+}
+
+func (q *PrefixQuery) Matches(value netip.Prefix) (bool, error) {
+	if q.err != nil {
+		return false, q.err
+	}
+	if !value.IsValid() {
+		return false, nil
+	}
+
+	c := q.criteria
+	if c == MatchPrefixWithin {
+		return q.prefixes.Covers(value), nil
+	} else if c == MatchPrefixContains {
+		return value.Contains(q.addr), nil
+	} else if c == MatchPrefixOverlaps {
+		return value.Overlaps(q.prefix), nil
+	}
+	return false, fmt.Errorf("QueryError: unsupported prefix matching strategy: %d", c)
+}
+
+func (q *PrefixQuery) MatchesOption(value optional.Optional[netip.Prefix]) (bool, error) {
+	if value.IsNone() {
+		return false, nil
+	}
+	return q.Matches(value.UnsafeUnwrap())
+}
+
+// PrefixSet is a set of IP prefixes stored in a binary trie with one level per bit of the address. IPv4 and IPv6
+// prefixes are kept in separate tries. A PrefixSet is read only once it has been created, so it can be shared.
+type PrefixSet struct {
+	v4, v6 *prefixNode
+	size   int
+}
+
+type prefixNode struct {
+	children [2]*prefixNode
+	// terminal is true if a prefix ends at this node
+	terminal bool
+}
+
+// NewPrefixSet creates a set from the prefixes. Host bits are ignored, so 10.1.2.3/8 is the same as 10.0.0.0/8.
+func NewPrefixSet(prefixes ...netip.Prefix) (*PrefixSet, error) {
+	s := &PrefixSet{v4: &prefixNode{}, v6: &prefixNode{}}
+	for _, p := range prefixes {
+		if !p.IsValid() {
+			return nil, fmt.Errorf("QueryError: invalid prefix %s", p)
+		}
+		p, ok := unmapPrefix(p)
+		if !ok {
+			return nil, fmt.Errorf("QueryError: IPv4-mapped prefix %s is shorter than the ::ffff:0:0/96 mapping prefix", p)
+		}
+		p = p.Masked()
+		node := s.root(p.Addr())
+		bytes := p.Addr().AsSlice()
+		for i := 0; i < p.Bits(); i++ {
+			b := addrBit(bytes, i)
+			if node.children[b] == nil {
+				node.children[b] = &prefixNode{}
+			}
+			node = node.children[b]
+		}
+		if !node.terminal {
+			node.terminal = true
+			s.size++
+		}
+	}
+	return s, nil
+}
+
+// unmapPrefix turns an IPv4-mapped IPv6 prefix like ::ffff:10.0.0.0/104 into the IPv4 prefix 10.0.0.0/8, since
+// addresses are unmapped before they are looked up. ok is false for mapped prefixes which are too short to be an IPv4
+// prefix.
+func unmapPrefix(p netip.Prefix) (netip.Prefix, bool) {
+	if !p.Addr().Is4In6() {
+		return p, true
+	}
+	if p.Bits() < 96 {
+		return p, false
+	}
+	return netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96), true
+}
+
+func (s *PrefixSet) root(addr netip.Addr) *prefixNode {
+	if addr.Is4() {
+		return s.v4
+	}
+	return s.v6
+}
+
+// Len returns the number of distinct prefixes in the set.
+func (s *PrefixSet) Len() int {
+	return s.size
+}
+
+// Contains reports whether addr is inside of any prefix in the set.
+func (s *PrefixSet) Contains(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	return s.covers(addr, addr.BitLen())
+}
+
+// Covers reports whether every address of prefix is inside of a single prefix in the set.
+func (s *PrefixSet) Covers(prefix netip.Prefix) bool {
+	if !prefix.IsValid() {
+		return false
+	}
+	prefix, ok := unmapPrefix(prefix)
+	if !ok {
+		return false
+	}
+	return s.covers(prefix.Addr(), prefix.Bits())
+}
+
+// covers walks the trie along the first bits bits of addr looking for a prefix which ends on the way.
+func (s *PrefixSet) covers(addr netip.Addr, bits int) bool {
+	node := s.root(addr)
+	bytes := addr.AsSlice()
+	for i := 0; ; i++ {
+		if node.terminal {
+			return true
+		}
+		if i == bits {
+			return false
+		}
+		node = node.children[addrBit(bytes, i)]
+		if node == nil {
+			return false
+		}
+	}
+}
+
+func addrBit(bytes []byte, i int) int {
+	return int(bytes[i/8]>>(7-i%8)) & 1
+}
@@ -0,0 +1,143 @@
+package query_test
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestAddrQuery(t *testing.T) {
+	cidr, err := smartquery.ParseCIDR("10.0.0.0/8", "192.168.1.0/24", "2001:db8::/32", "172.16.5.9/12")
+	assert.NilError(t, err)
+
+	cases := []struct {
+		name    string
+		query   smartquery.AddrQuery
+		value   string
+		matches bool
+	}{
+		{"cidr", cidr, "10.200.3.4", true},
+		{"cidr v6", cidr, "2001:db8:1::1", true},
+		{"cidr host bits ignored", cidr, "172.20.0.1", true},
+		{"cidr outside", cidr, "192.168.2.1", false},
+		{"cidr mapped", cidr, "::ffff:10.0.0.1", true},
+		{"cidr wrong family", cidr, "::a00:1", false},
+		{"private", smartquery.IsPrivate(), "192.168.0.1", true},
+		{"private v6", smartquery.IsPrivate(), "fd00::1", true},
+		{"public", smartquery.IsPrivate(), "8.8.8.8", false},
+		{"loopback", smartquery.IsLoopback(), "127.0.0.2", true},
+		{"loopback v6", smartquery.IsLoopback(), "::1", true},
+		{"not loopback", smartquery.IsLoopback(), "10.0.0.1", false},
+		{"family", smartquery.SameFamily(netip.MustParseAddr("1.2.3.4")), "::ffff:8.8.8.8", true},
+		{"other family", smartquery.SameFamily(netip.MustParseAddr("1.2.3.4")), "2001:db8::1", false},
+	}
+
+	for _, c := range cases {
+		addr := netip.MustParseAddr(c.value)
+		matches, err := c.query.Matches(addr)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, c.name)
+
+		some := optional.NewOption(addr)
+		matches, err = c.query.MatchesOption(&some)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, c.name)
+
+		none := optional.None[netip.Addr]()
+		matches, err = c.query.MatchesOption(&none)
+		assert.NilError(t, err)
+		assert.Assert(t, !matches, "%s: address query matched option with None value!", c.name)
+
+		matches, err = c.query.Matches(netip.Addr{})
+		assert.NilError(t, err)
+		assert.Assert(t, !matches, "%s: address query matched the zero address!", c.name)
+	}
+
+	_, err = smartquery.ParseCIDR("10.0.0.0/33")
+	assert.ErrorContains(t, err, "QueryError")
+
+	invalid := smartquery.InCIDR(netip.Prefix{})
+	_, err = invalid.Matches(netip.MustParseAddr("10.0.0.1"))
+	assert.ErrorContains(t, err, "invalid prefix")
+}
+
+func TestPrefixQuery(t *testing.T) {
+	within := smartquery.PrefixWithin(netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::/32"))
+
+	cases := []struct {
+		name    string
+		query   smartquery.PrefixQuery
+		value   string
+		matches bool
+	}{
+		{"within", within, "10.1.0.0/16", true},
+		{"within same", within, "10.0.0.0/8", true},
+		{"within larger", within, "10.0.0.0/7", false},
+		{"within v6", within, "2001:db8:ff00::/40", true},
+		{"within other", within, "11.0.0.0/16", false},
+		{"contains", smartquery.PrefixContains(netip.MustParseAddr("10.1.2.3")), "10.1.0.0/16", true},
+		{"contains outside", smartquery.PrefixContains(netip.MustParseAddr("10.2.2.3")), "10.1.0.0/16", false},
+		{"overlaps", smartquery.PrefixOverlaps(netip.MustParsePrefix("10.1.2.0/24")), "10.0.0.0/8", true},
+		{"overlaps inside", smartquery.PrefixOverlaps(netip.MustParsePrefix("10.0.0.0/8")), "10.1.2.0/24", true},
+		{"overlaps disjoint", smartquery.PrefixOverlaps(netip.MustParsePrefix("10.0.0.0/16")), "10.1.0.0/16", false},
+	}
+
+	for _, c := range cases {
+		prefix := netip.MustParsePrefix(c.value)
+		matches, err := c.query.Matches(prefix)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, c.name)
+
+		none := optional.None[netip.Prefix]()
+		matches, err = c.query.MatchesOption(&none)
+		assert.NilError(t, err)
+		assert.Assert(t, !matches, "%s: prefix query matched option with None value!", c.name)
+	}
+}
+
+func TestPrefixSet(t *testing.T) {
+	var prefixes []netip.Prefix
+	for i := 0; i < 4096; i++ {
+		prefixes = append(prefixes, netip.MustParsePrefix(fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)))
+	}
+	// Duplicates only count once
+	prefixes = append(prefixes, netip.MustParsePrefix("10.0.0.0/24"))
+	set, err := smartquery.NewPrefixSet(prefixes...)
+	assert.NilError(t, err)
+	assert.Equal(t, set.Len(), 4096)
+
+	assert.Assert(t, set.Contains(netip.MustParseAddr("10.15.255.7")))
+	assert.Assert(t, !set.Contains(netip.MustParseAddr("10.16.0.1")))
+	assert.Assert(t, set.Covers(netip.MustParsePrefix("10.3.4.128/25")))
+	assert.Assert(t, !set.Covers(netip.MustParsePrefix("10.3.0.0/16")))
+
+	empty, err := smartquery.NewPrefixSet()
+	assert.NilError(t, err)
+	assert.Assert(t, !empty.Contains(netip.MustParseAddr("10.0.0.1")))
+
+	all, err := smartquery.NewPrefixSet(netip.MustParsePrefix("0.0.0.0/0"))
+	assert.NilError(t, err)
+	assert.Assert(t, all.Contains(netip.MustParseAddr("1.2.3.4")))
+	assert.Assert(t, !all.Contains(netip.MustParseAddr("::1")))
+
+	// IPv4-mapped prefixes are stored as the IPv4 prefixes they map, since addresses are unmapped before lookups
+	mapped, err := smartquery.NewPrefixSet(netip.MustParsePrefix("::ffff:10.0.0.0/104"))
+	assert.NilError(t, err)
+	assert.Assert(t, mapped.Contains(netip.MustParseAddr("10.1.2.3")))
+	assert.Assert(t, mapped.Contains(netip.MustParseAddr("::ffff:10.1.2.3")))
+	assert.Assert(t, !mapped.Contains(netip.MustParseAddr("11.1.2.3")))
+	assert.Assert(t, mapped.Covers(netip.MustParsePrefix("10.1.0.0/16")))
+	assert.Assert(t, mapped.Covers(netip.MustParsePrefix("::ffff:10.1.0.0/112")))
+
+	q := smartquery.InCIDR(netip.MustParsePrefix("::ffff:10.0.0.0/104"))
+	matches, err := q.Matches(netip.MustParseAddr("10.1.2.3"))
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "IPv4-mapped CIDR did not match an IPv4 address inside of it!")
+
+	_, err = smartquery.NewPrefixSet(netip.MustParsePrefix("::ffff:10.0.0.0/95"))
+	assert.ErrorContains(t, err, "IPv4-mapped prefix")
+}
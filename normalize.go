@@ -0,0 +1,65 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/brnsampson/optional"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization is a set of transformations applied to both sides of a string comparison so that strings which look
+// the same to a person compare equal. Flags can be combined with |.
+type Normalization uint8
+
+const (
+	NormalizeNFC     Normalization = 1 << iota // Unicode canonical composition, so "é" and "e" + U+0301 are equal
+	NormalizeNFKC                              // Compatibility composition, so "ﬁ" and "fi" or "①" and "1" are also equal
+	NormalizeCase                              // Full Unicode case folding, so "Straße" and "STRASSE" are equal
+	NormalizeAccents                           // Remove accents and other combining marks, so "café" and "cafe" are equal
+)
+
+// apply transforms s. Any normalization at all also puts s into NFC (or NFKC), since folding case or removing accents
+// from strings in different forms would otherwise still leave them different.
+func (n Normalization) apply(s string) string {
+	if n == 0 {
+		return s
+	}
+
+	form := norm.NFC
+	if n&NormalizeNFKC != 0 {
+		form = norm.NFKC
+	}
+	s = form.String(s)
+	if n&NormalizeCase != 0 {
+		s = cases.Fold().String(s)
+	}
+	if n&NormalizeAccents != 0 {
+		s = strings.Map(func(r rune) rune {
+			if unicode.Is(unicode.Mn, r) {
+				return -1
+			}
+			return r
+		}, norm.NFD.String(s))
+	}
+	// Case folding and removing marks can both leave s in a different form than it started in
+	return form.String(s)
+}
+
+// WithNormalization returns a copy of the query which normalizes strings before comparing them. The pattern is
+// normalized once here, including the pattern of a LIKE query, and each value is normalized when it is matched.
+// Normalizing is not reversible, so set every flag you need in one call rather than calling this more than once.
+func (q StringQuery) WithNormalization(n Normalization) StringQuery {
+	if !q.value.IsNone() {
+		q.value = optional.NewOption(n.apply(q.value.UnsafeUnwrap())).AsRef()
+		q.snapshot()
+	}
+	q.normalization = n
+	q.pattern, _ = q.compile()
+	return q
+}
+
+func (q *StringQuery) Normalization() Normalization {
+	return q.normalization
+}
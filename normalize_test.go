@@ -0,0 +1,63 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestStringNormalization(t *testing.T) {
+	composed := "café"
+	decomposed := "café"
+
+	cases := []struct {
+		name    string
+		query   smartquery.StringQuery
+		value   string
+		matches bool
+	}{
+		{"raw", smartquery.ExactString(composed), decomposed, false},
+		{"nfc", smartquery.ExactString(composed).WithNormalization(smartquery.NormalizeNFC), decomposed, true},
+		{"nfc pattern", smartquery.ExactString(decomposed).WithNormalization(smartquery.NormalizeNFC), composed, true},
+		{"nfc keeps accents", smartquery.ExactString(composed).WithNormalization(smartquery.NormalizeNFC), "cafe", false},
+		{"nfc keeps ligatures", smartquery.ExactString("file").WithNormalization(smartquery.NormalizeNFC), "ﬁle", false},
+		{"nfkc", smartquery.ExactString("file").WithNormalization(smartquery.NormalizeNFKC), "ﬁle", true},
+		{"case", smartquery.ExactString("Straße").WithNormalization(smartquery.NormalizeCase), "STRASSE", true},
+		{"case sigma", smartquery.ExactString("ΟΔΟΣ").WithNormalization(smartquery.NormalizeCase), "οδος", true},
+		{"accents", smartquery.ExactString("cafe").WithNormalization(smartquery.NormalizeAccents), decomposed, true},
+		{"accents composed", smartquery.ExactString("Zoë").WithNormalization(smartquery.NormalizeAccents), "Zoe", true},
+		{"accents keep case", smartquery.ExactString("Zoë").WithNormalization(smartquery.NormalizeAccents), "zoe", false},
+		{"like", smartquery.LikeString("CAFE%").WithNormalization(smartquery.NormalizeCase | smartquery.NormalizeAccents), composed + " au lait", true},
+		{"like single rune", smartquery.LikeString("caf_").WithNormalization(smartquery.NormalizeNFC), decomposed, true},
+		{"like single rune after", smartquery.LikeString("caf_ au lait").WithNormalization(smartquery.NormalizeNFC), decomposed + " au lait", true},
+		{"like raw", smartquery.LikeString("caf_ au lait"), decomposed + " au lait", false},
+	}
+
+	for _, c := range cases {
+		matches, err := c.query.Matches(c.value)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, c.name)
+
+		some := optional.NewOption(c.value)
+		matches, err = c.query.MatchesOption(&some)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, c.name)
+
+		none := optional.None[string]()
+		matches, err = c.query.MatchesOption(&none)
+		assert.NilError(t, err)
+		assert.Assert(t, !matches, "%s: normalized string query matched option with None value!", c.name)
+	}
+}
+
+func TestNormalizedIndexLookup(t *testing.T) {
+	c := smartquery.NewCollection[testStruct](smartquery.HashIndex(nameField))
+	c.Insert(testStruct{Name: "CAFÉ", Email: optional.None[string]().AsRef(), Stars: optional.None[int]().AsRef()})
+
+	q := smartquery.ExactString("café").WithNormalization(smartquery.NormalizeNFC | smartquery.NormalizeCase)
+	found, err := c.Find(nameField.Where(q.AsRef()).AsRef())
+	assert.NilError(t, err)
+	assert.Equal(t, len(found), 1, "Normalized query was answered from an index of raw strings!")
+}
@@ -0,0 +1,323 @@
+package query
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Statistics supplies the selectivity of queries to Optimize: the fraction of records, from 0 to 1, a query is expected
+// to match. It is asked about every node of the query tree and returns false for the ones it knows nothing about.
+type Statistics interface {
+	Selectivity(query any) (float64, bool)
+}
+
+// StatisticsFunc adapts a function to the Statistics interface.
+type StatisticsFunc func(query any) (float64, bool)
+
+func (f StatisticsFunc) Selectivity(query any) (float64, bool) {
+	return f(query)
+}
+
+// FieldSelectivity gives the selectivity of any query on a field (made with Field.Where) by the name of the field.
+type FieldSelectivity map[string]float64
+
+func (s FieldSelectivity) Selectivity(query any) (float64, bool) {
+	p, ok := query.(fieldPredicate)
+	if !ok {
+		return 0, false
+	}
+	selectivity, ok := s[p.fieldName()]
+	return selectivity, ok
+}
+
+// CostHint can be implemented by custom queries to tell Optimize how expensive they are to evaluate. Costs are relative:
+// comparing two values for equality costs 1. Queries without a hint are assumed to cost 10.
+type CostHint interface {
+	Cost() float64
+}
+
+const (
+	defaultCost        = 10
+	defaultSelectivity = 0.5
+)
+
+// Optimize returns a copy of the query with the children of every And and Or reordered so that the cheapest and most
+// decisive ones run first: And children which are cheap and rarely match, and Or children which are cheap and often
+// match. Nested Ands in an And (and Ors in an Or) are flattened first. stats may be nil, in which case every query is
+// assumed to match half of the time and only the built-in cost estimates are used. Parts of the query without an And
+// or Or in them are shared with the original.
+//
+// The result matches exactly the same values as the original query. Errors are different: since And and Or stop at the
+// first child which decides the result, a child which would have returned an error may not be evaluated at all, or a
+// different child's error may be returned first. The order only depends on the query and stats, so it is the same
+// every time for the same inputs. Don't optimize queries whose errors you rely on.
+func Optimize[T comparable](query Query[T], stats Statistics) Query[T] {
+	o := optimizer{stats}
+	return optimizeQuery(&o, query)
+}
+
+type optimizer struct {
+	stats Statistics
+}
+
+// optimizable is implemented by queries which contain other queries.
+type optimizable[T comparable] interface {
+	optimize(o *optimizer) Query[T]
+}
+
+func optimizeQuery[T comparable](o *optimizer, query Query[T]) Query[T] {
+	if q, ok := query.(optimizable[T]); ok {
+		return q.optimize(o)
+	}
+	return query
+}
+
+// estimator is implemented by the queries in this package to estimate their cost.
+type estimator interface {
+	estimateCost() float64
+}
+
+// selectivityEstimator is implemented by queries which can do better than the default selectivity, mostly by
+// combining the selectivity of the queries they contain.
+type selectivityEstimator interface {
+	estimateSelectivity(o *optimizer) float64
+}
+
+func costOf(query any) float64 {
+	if q, ok := query.(CostHint); ok {
+		return q.Cost()
+	} else if q, ok := query.(estimator); ok {
+		return q.estimateCost()
+	}
+	return defaultCost
+}
+
+func (o *optimizer) selectivity(query any) float64 {
+	if o.stats != nil {
+		if s, ok := o.stats.Selectivity(query); ok {
+			return min(max(s, 0), 1)
+		}
+	}
+	if q, ok := query.(selectivityEstimator); ok {
+		return q.estimateSelectivity(o)
+	}
+	return defaultSelectivity
+}
+
+// rankChildren orders children so that the expected cost of evaluating them in order is as low as possible. A child's rank
+// is its cost divided by the chance that it ends the evaluation, which for And is the chance it doesn't match.
+func rankChildren[T comparable](o *optimizer, children []Query[T], and bool) []Query[T] {
+	type ranked struct {
+		query Query[T]
+		rank  float64
+	}
+	out := make([]ranked, len(children))
+	for i, child := range children {
+		decisive := o.selectivity(child)
+		if and {
+			decisive = 1 - decisive
+		}
+		// A child which never decides anything goes last; the small constant keeps the division finite
+		out[i] = ranked{child, costOf(child) / max(decisive, 1e-9)}
+	}
+	slices.SortStableFunc(out, func(a, b ranked) int { return cmp.Compare(a.rank, b.rank) })
+
+	queries := make([]Query[T], len(out))
+	for i, r := range out {
+		queries[i] = r.query
+	}
+	return queries
+}
+
+func (q *AndQuery[T]) optimize(o *optimizer) Query[T] {
+	var children []Query[T]
+	for _, child := range flattenAnd[T](q) {
+		children = append(children, optimizeQuery(o, child))
+	}
+	return And(rankChildren(o, children, true)...).AsRef()
+}
+
+func (q *OrQuery[T]) optimize(o *optimizer) Query[T] {
+	var children []Query[T]
+	for _, child := range flattenOr[T](q) {
+		children = append(children, optimizeQuery(o, child))
+	}
+	return Or(rankChildren(o, children, false)...).AsRef()
+}
+
+// flattenOr is flattenAnd for Or-trees.
+func flattenOr[T comparable](query Query[T]) []Query[T] {
+	or, ok := query.(*OrQuery[T])
+	if !ok {
+		return []Query[T]{query}
+	}
+	var out []Query[T]
+	for _, child := range or.children {
+		out = append(out, flattenOr(child)...)
+	}
+	return out
+}
+
+func (q *NotQuery[T]) optimize(o *optimizer) Query[T] {
+	child := optimizeQuery(o, q.child)
+	if child == q.child {
+		return q
+	}
+	return Not(child).AsRef()
+}
+
+func (p *FieldPredicate[T, F]) optimize(o *optimizer) Query[T] {
+	query := optimizeQuery(o, p.query)
+	if query == p.query {
+		return p
+	}
+	return p.field.Where(query).AsRef()
+}
+
+func (q *AndQuery[T]) estimateCost() float64 {
+	return sumCosts(q.children)
+}
+
+func (q *AndQuery[T]) estimateSelectivity(o *optimizer) float64 {
+	s := 1.0
+	for _, child := range q.children {
+		s *= o.selectivity(child)
+	}
+	return s
+}
+
+func (q *OrQuery[T]) estimateCost() float64 {
+	return sumCosts(q.children)
+}
+
+func (q *OrQuery[T]) estimateSelectivity(o *optimizer) float64 {
+	none := 1.0
+	for _, child := range q.children {
+		none *= 1 - o.selectivity(child)
+	}
+	return 1 - none
+}
+
+func (q *NotQuery[T]) estimateCost() float64 {
+	return costOf(q.child)
+}
+
+func (q *NotQuery[T]) estimateSelectivity(o *optimizer) float64 {
+	return 1 - o.selectivity(q.child)
+}
+
+func sumCosts[T comparable](queries []Query[T]) float64 {
+	total := 0.0
+	for _, q := range queries {
+		total += costOf(q)
+	}
+	return total
+}
+
+func (p *FieldPredicate[T, F]) estimateCost() float64 {
+	return 0.5 + costOf(p.query)
+}
+
+func (p *FieldPredicate[T, F]) estimateSelectivity(o *optimizer) float64 {
+	return o.selectivity(p.query)
+}
+
+func (q *PathQuery[T, F]) estimateCost() float64 {
+	// Walking the path uses reflection
+	return 3 + costOf(q.query)
+}
+
+func (q *DocQuery[F]) estimateCost() float64 {
+	return 2 + costOf(q.query)
+}
+
+func (p *MapPredicate[T, K, V]) estimateCost() float64 {
+	total := 1.0
+	for _, r := range p.query.requirements {
+		if r.query != nil {
+			total += costOf(r.query)
+		}
+	}
+	return total
+}
+
+func (p *SlicePredicate[T, E]) estimateCost() float64 {
+	// Guess at a few elements per slice
+	total := 1.0
+	for _, r := range p.query.requirements {
+		if r.query != nil {
+			total += 4 * costOf(r.query)
+		}
+	}
+	return total
+}
+
+func (q *RefQuery[T]) estimateCost() float64 {
+	target, err := q.Resolve()
+	if err != nil {
+		return defaultCost
+	}
+	return costOf(target)
+}
+
+func (q *FieldQuery[T]) estimateCost() float64 {
+	return 1
+}
+
+func (q *FieldQuery[T]) estimateSelectivity(o *optimizer) float64 {
+	if q.criteria == MatchAlways {
+		return 1
+	}
+	return defaultSelectivity
+}
+
+func (q *StringQuery) estimateCost() float64 {
+	cost := 1.0
+	if q.criteria == MatchLike || q.criteria == MatchGlob {
+		cost = 8
+	} else if q.criteria == MatchTrigram {
+		cost = 25
+	} else if q.criteria.fuzzy() {
+		cost = 15
+	}
+	if q.normalization != 0 {
+		cost += 3
+	}
+	return cost
+}
+
+func (q *StringQuery) estimateSelectivity(o *optimizer) float64 {
+	if q.criteria == MatchAlways {
+		return 1
+	}
+	return defaultSelectivity
+}
+
+func (q *RangeQuery[T]) estimateCost() float64 {
+	return 1
+}
+
+func (q *InQuery[T]) estimateCost() float64 {
+	return 1
+}
+
+func (q *FloatQuery[T]) estimateCost() float64 {
+	return 1
+}
+
+func (q *TimeQuery) estimateCost() float64 {
+	return 2
+}
+
+func (q *AddrQuery) estimateCost() float64 {
+	return 2
+}
+
+func (q *PrefixQuery) estimateCost() float64 {
+	return 2
+}
+
+func (q *VersionQuery) estimateCost() float64 {
+	// Every match parses the version
+	return 5
+}
@@ -0,0 +1,88 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestOptimizeOrder(t *testing.T) {
+	fuzzy := nameField.Where(smartquery.Trigram("chester", 0.3).AsRef()).AsRef()
+	like := nameField.Where(smartquery.LikeString("C%").AsRef()).AsRef()
+	exact := balanceField.Where(smartquery.Exact(42).AsRef()).AsRef()
+	stars := starsField.Where(smartquery.AtLeast(3).AsRef()).AsRef()
+
+	q := smartquery.And[testStruct](fuzzy, smartquery.And[testStruct](like, exact).AsRef(), stars).AsRef()
+	optimized := smartquery.Optimize[testStruct](q, nil)
+	and, ok := optimized.(*smartquery.AndQuery[testStruct])
+	assert.Assert(t, ok)
+	// Cheap equality and range checks first, in their original order, then the pattern, then the fuzzy match
+	assertSameQueries(t, and.Children(), exact, stars, like, fuzzy)
+
+	// With statistics saying stars almost always matches, it is not worth running early
+	stats := smartquery.FieldSelectivity{"stars": 0.99, "balance": 0.4}
+	and = smartquery.Optimize[testStruct](q, stats).(*smartquery.AndQuery[testStruct])
+	assertSameQueries(t, and.Children(), exact, like, fuzzy, stars)
+
+	// In an Or the children most likely to match go first
+	or := smartquery.Optimize[testStruct](smartquery.Or[testStruct](exact, stars).AsRef(), stats).(*smartquery.OrQuery[testStruct])
+	assertSameQueries(t, or.Children(), stars, exact)
+
+	// The original query is left alone
+	assert.Equal(t, len(q.Children()), 3)
+}
+
+func TestOptimizeCostHints(t *testing.T) {
+	slow := smartquery.Predicate("slow", func(v int) (bool, error) { return true, nil }).WithCost(100).AsRef()
+	fast := smartquery.Predicate("fast", func(v int) (bool, error) { return true, nil }).WithCost(0.1).AsRef()
+	q := smartquery.Or[int](
+		slow,
+		smartquery.Not[int](smartquery.And[int](smartquery.Exact(1).AsRef(), smartquery.Or[int](slow, fast).AsRef()).AsRef()).AsRef(),
+		fast,
+	).AsRef()
+
+	optimized := smartquery.Optimize[int](q, nil)
+	or := optimized.(*smartquery.OrQuery[int])
+	assert.Equal(t, or.Children()[0], smartquery.Query[int](fast))
+	assert.Equal(t, or.Children()[2], smartquery.Query[int](slow))
+
+	// Nested queries are optimized too
+	not := or.Children()[1].(*smartquery.NotQuery[int])
+	inner := not.Child().(*smartquery.AndQuery[int]).Children()[1].(*smartquery.OrQuery[int])
+	assert.Equal(t, inner.Children()[0], smartquery.Query[int](fast))
+}
+
+func TestOptimizeSameResults(t *testing.T) {
+	q := smartquery.And[testStruct](
+		nameField.Where(smartquery.WithinEdits("Chester", 2).AsRef()).AsRef(),
+		smartquery.Or[testStruct](
+			emailField.Where(smartquery.LikeString("%@testing.org").AsRef()).AsRef(),
+			starsField.Where(smartquery.Not[int](smartquery.AtLeast(5).AsRef()).AsRef()).AsRef(),
+		).AsRef(),
+		balanceField.Where(smartquery.Between(0, 100).AsRef()).AsRef(),
+	).AsRef()
+	optimized := smartquery.Optimize[testStruct](q, smartquery.FieldSelectivity{"email": 0.1})
+
+	for _, name := range []string{"Chester", "Chestr", "Bob"} {
+		for _, balance := range []int{-1, 50} {
+			for _, email := range []string{"c@testing.org", "c@example.com"} {
+				s := testStruct{Name: name, Email: optional.NewOption(email).AsRef(), Balance: balance, Stars: optional.NewOption(7).AsRef()}
+				expected, err := q.Matches(s)
+				assert.NilError(t, err)
+				actual, err := optimized.Matches(s)
+				assert.NilError(t, err)
+				assert.Equal(t, actual, expected, "%s %d %s", name, balance, email)
+			}
+		}
+	}
+}
+
+func assertSameQueries[T comparable](t *testing.T, actual []smartquery.Query[T], expected ...smartquery.Query[T]) {
+	t.Helper()
+	assert.Equal(t, len(actual), len(expected))
+	for i := range expected {
+		assert.Assert(t, actual[i] == expected[i], "child %d", i)
+	}
+}
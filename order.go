@@ -0,0 +1,223 @@
+package query
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/brnsampson/optional"
+)
+
+// NonePlacement controls where records with a None value for a sort key end up in the order. The default is NonesLast.
+type NonePlacement int
+
+const (
+	NonesLast NonePlacement = iota
+	NonesFirst
+)
+
+func (p NonePlacement) MarshalText() ([]byte, error) {
+	switch p {
+	case NonesLast:
+		return []byte("last"), nil
+	case NonesFirst:
+		return []byte("first"), nil
+	}
+	return nil, fmt.Errorf("PageError: unsupported none placement: %d", p)
+}
+
+func (p *NonePlacement) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "last", "":
+		*p = NonesLast
+	case "first":
+		*p = NonesFirst
+	default:
+		return fmt.Errorf("PageError: unsupported none placement: %q", text)
+	}
+	return nil
+}
+
+// SortKey is one key of a multi-key ordering. It only refers to the field by name so that it can be serialized and
+// saved along with the rest of a search; the name is resolved against the fields of a Sorter when it is used.
+type SortKey struct {
+	Field      string        `json:"field"`
+	Descending bool          `json:"descending,omitempty"`
+	Nones      NonePlacement `json:"nones,omitempty"`
+}
+
+func Asc(field string) SortKey {
+	return SortKey{Field: field}
+}
+
+func Desc(field string) SortKey {
+	return SortKey{Field: field, Descending: true}
+}
+
+func (k SortKey) NonesFirst() SortKey {
+	k.Nones = NonesFirst
+	return k
+}
+
+func (k SortKey) NonesLast() SortKey {
+	k.Nones = NonesLast
+	return k
+}
+
+// SortField is a field that records can be ordered by. Create one from a Field with Sortable.
+type SortField[T comparable] interface {
+	Name() string
+
+	compare(a, b T, key SortKey) int
+	// encode returns the value of the field as JSON for use in a cursor. None is encoded as null.
+	encode(record T) (json.RawMessage, error)
+	// cursor decodes a value produced by encode and returns a function comparing records to it.
+	cursor(value json.RawMessage, key SortKey) (func(T) int, error)
+}
+
+type sortField[T comparable, F cmp.Ordered] struct {
+	field Field[T, F]
+}
+
+func Sortable[T comparable, F cmp.Ordered](field Field[T, F]) SortField[T] {
+	return &sortField[T, F]{field}
+}
+
+func (s *sortField[T, F]) Name() string {
+	return s.field.name
+}
+
+func (s *sortField[T, F]) compare(a, b T, key SortKey) int {
+	return compareOptions(s.field.Get(a), s.field.Get(b), key)
+}
+
+func (s *sortField[T, F]) encode(record T) (json.RawMessage, error) {
+	value := s.field.Get(record)
+	if value.IsNone() {
+		return json.RawMessage("null"), nil
+	}
+	v := value.UnsafeUnwrap()
+	// JSON has no NaN or infinities, so those are encoded as the strings strconv.ParseFloat understands
+	if rv := reflect.ValueOf(v); rv.CanFloat() {
+		if f := rv.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return json.Marshal(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	}
+	return json.Marshal(v)
+}
+
+func (s *sortField[T, F]) cursor(raw json.RawMessage, key SortKey) (func(T) int, error) {
+	var value *F
+	if err := json.Unmarshal(raw, &value); err != nil {
+		// Floats can also be a NaN or an infinity, see encode
+		f, ok := decodeNonFinite(raw)
+		if k := reflect.TypeFor[F]().Kind(); !ok || (k != reflect.Float32 && k != reflect.Float64) {
+			return nil, fmt.Errorf("PageError: invalid cursor value for field %s: %w", s.field.name, err)
+		}
+		value = new(F)
+		reflect.ValueOf(value).Elem().SetFloat(f)
+	}
+
+	var at optional.Optional[F] = optional.None[F]().AsRef()
+	if value != nil {
+		at = optional.NewOption(*value).AsRef()
+	}
+	return func(record T) int {
+		return compareOptions(s.field.Get(record), at, key)
+	}, nil
+}
+
+// decodeNonFinite decodes a NaN or an infinity encoded as a string by sortField.encode.
+func decodeNonFinite(raw json.RawMessage) (float64, bool) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || !(math.IsNaN(f) || math.IsInf(f, 0)) {
+		return 0, false
+	}
+	return f, true
+}
+
+// compareOptions compares two field values according to a sort key. None values are placed according to key.Nones
+// regardless of the direction of the key.
+func compareOptions[F cmp.Ordered](a, b optional.Optional[F], key SortKey) int {
+	aNone, bNone := a.IsNone(), b.IsNone()
+	if aNone && bNone {
+		return 0
+	} else if aNone || bNone {
+		c := 1
+		if key.Nones == NonesFirst {
+			c = -1
+		}
+		if bNone {
+			c = -c
+		}
+		return c
+	}
+
+	c := cmp.Compare(a.UnsafeUnwrap(), b.UnsafeUnwrap())
+	if key.Descending {
+		c = -c
+	}
+	return c
+}
+
+// Sorter orders records by a list of SortKeys, resolving the field names in the keys against the fields it was created
+// with.
+type Sorter[T comparable] struct {
+	fields map[string]SortField[T]
+}
+
+func NewSorter[T comparable](fields ...SortField[T]) *Sorter[T] {
+	s := &Sorter[T]{make(map[string]SortField[T], len(fields))}
+	for _, f := range fields {
+		s.fields[f.Name()] = f
+	}
+	return s
+}
+
+// Compare returns a comparison function for the given keys which can be used with slices.SortStableFunc and friends.
+// Records which are equal on every key compare as equal.
+func (s *Sorter[T]) Compare(keys ...SortKey) (func(a, b T) int, error) {
+	fields, err := s.resolve(keys)
+	if err != nil {
+		return nil, err
+	}
+	return func(a, b T) int {
+		for i, f := range fields {
+			if c := f.compare(a, b, keys[i]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}, nil
+}
+
+// Sort sorts records in place by the given keys. The sort is stable, so records which are equal on every key keep their
+// original order.
+func (s *Sorter[T]) Sort(records []T, keys ...SortKey) error {
+	compare, err := s.Compare(keys...)
+	if err != nil {
+		return err
+	}
+	slices.SortStableFunc(records, compare)
+	return nil
+}
+
+func (s *Sorter[T]) resolve(keys []SortKey) ([]SortField[T], error) {
+	fields := make([]SortField[T], len(keys))
+	for i, k := range keys {
+		f, ok := s.fields[k.Field]
+		if !ok {
+			return nil, fmt.Errorf("PageError: cannot sort by unknown field %q", k.Field)
+		}
+		fields[i] = f
+	}
+	return fields, nil
+}
@@ -0,0 +1,72 @@
+package query_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+var testSorter = smartquery.NewSorter(
+	smartquery.Sortable(nameField),
+	smartquery.Sortable(balanceField),
+	smartquery.Sortable(starsField),
+)
+
+func names(records []testStruct) []string {
+	out := make([]string, len(records))
+	for i, r := range records {
+		out[i] = r.Name
+	}
+	return out
+}
+
+func sortRecords() []testStruct {
+	star := func(n int) optional.Optional[int] { return optional.NewOption(n).AsRef() }
+	none := optional.None[int]().AsRef()
+	return []testStruct{
+		{Name: "a", Balance: 2, Stars: star(1)},
+		{Name: "b", Balance: 1, Stars: none},
+		{Name: "c", Balance: 2, Stars: star(3)},
+		{Name: "d", Balance: 1, Stars: star(2)},
+		{Name: "e", Balance: 3, Stars: none},
+	}
+}
+
+func TestSorterSort(t *testing.T) {
+	records := sortRecords()
+
+	assert.NilError(t, testSorter.Sort(records, smartquery.Asc("balance")))
+	assert.DeepEqual(t, names(records), []string{"b", "d", "a", "c", "e"})
+
+	assert.NilError(t, testSorter.Sort(records, smartquery.Desc("balance"), smartquery.Asc("name")))
+	assert.DeepEqual(t, names(records), []string{"e", "a", "c", "b", "d"})
+
+	assert.NilError(t, testSorter.Sort(records, smartquery.Asc("stars")))
+	assert.DeepEqual(t, names(records), []string{"a", "d", "c", "e", "b"})
+
+	assert.NilError(t, testSorter.Sort(records, smartquery.Desc("stars"), smartquery.Asc("name")))
+	assert.DeepEqual(t, names(records), []string{"c", "d", "a", "b", "e"})
+
+	assert.NilError(t, testSorter.Sort(records, smartquery.Desc("stars").NonesFirst(), smartquery.Asc("name")))
+	assert.DeepEqual(t, names(records), []string{"b", "e", "c", "d", "a"})
+
+	err := testSorter.Sort(records, smartquery.Asc("email"))
+	assert.ErrorContains(t, err, "PageError")
+}
+
+func TestSortKeyJSON(t *testing.T) {
+	keys := []smartquery.SortKey{smartquery.Asc("name"), smartquery.Desc("stars").NonesFirst()}
+	raw, err := json.Marshal(keys)
+	assert.NilError(t, err)
+	assert.Equal(t, string(raw), `[{"field":"name"},{"field":"stars","descending":true,"nones":"first"}]`)
+
+	var decoded []smartquery.SortKey
+	assert.NilError(t, json.Unmarshal(raw, &decoded))
+	assert.DeepEqual(t, decoded, keys)
+
+	err = json.Unmarshal([]byte(`[{"field":"name","nones":"middle"}]`), &decoded)
+	assert.ErrorContains(t, err, "PageError")
+}
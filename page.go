@@ -0,0 +1,143 @@
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Page describes one page of an ordered result set. It is plain data and can be serialized as JSON so that a saved
+// search describes exactly which page of results it shows.
+//
+// Offset is applied after the cursor in After, if there is one. A Limit of zero means no limit.
+type Page struct {
+	OrderBy []SortKey `json:"order_by,omitempty"`
+	Limit   int       `json:"limit,omitempty"`
+	Offset  int       `json:"offset,omitempty"`
+	After   string    `json:"after,omitempty"`
+}
+
+// Next returns the page following this one given the cursor returned with this page's results.
+func (p Page) Next(cursor string) Page {
+	p.After = cursor
+	p.Offset = 0
+	return p
+}
+
+// pageCursor is the decoded form of a cursor. It holds the sort key values of the last record of a page, and since
+// several records can share those values, how many of the records sharing them have already been returned.
+type pageCursor struct {
+	Keys   []SortKey         `json:"k"`
+	Values []json.RawMessage `json:"v"`
+	Skip   int               `json:"s"`
+}
+
+// Page sorts a copy of records according to page.OrderBy and returns the requested page along with a cursor for the
+// next page. The cursor is empty when there are no more records. Cursors hold the sort key values of the last record
+// rather than its position, so records inserted or removed ahead of the cursor don't shift the following pages.
+func (s *Sorter[T]) Page(records []T, page Page) ([]T, string, error) {
+	if page.Limit < 0 || page.Offset < 0 {
+		return nil, "", fmt.Errorf("PageError: limit and offset cannot be negative")
+	}
+	fields, err := s.resolve(page.OrderBy)
+	if err != nil {
+		return nil, "", err
+	}
+	compare, err := s.Compare(page.OrderBy...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sorted := slices.Clone(records)
+	slices.SortStableFunc(sorted, compare)
+
+	start := 0
+	if page.After != "" {
+		start, err = s.seek(sorted, fields, page)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	// Offset and limit come from the caller and can be anything up to MaxInt, so compare them to what is left rather
+	// than adding them to start
+	if page.Offset >= len(sorted)-start {
+		start = len(sorted)
+	} else {
+		start += page.Offset
+	}
+	end := len(sorted)
+	if page.Limit > 0 && page.Limit < len(sorted)-start {
+		end = start + page.Limit
+	}
+
+	out := sorted[start:end]
+	if end == len(sorted) || len(out) == 0 {
+		return out, "", nil
+	}
+
+	last := out[len(out)-1]
+	c := pageCursor{Keys: page.OrderBy, Values: make([]json.RawMessage, len(fields))}
+	for i, f := range fields {
+		c.Values[i], err = f.encode(last)
+		if err != nil {
+			return nil, "", fmt.Errorf("PageError: cannot encode cursor value for field %s: %w", f.Name(), err)
+		}
+	}
+	for i := end - 1; i >= 0 && compare(sorted[i], last) == 0; i-- {
+		c.Skip++
+	}
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return nil, "", err
+	}
+	return out, base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// seek returns the index of the first record after the cursor in page.After.
+func (s *Sorter[T]) seek(sorted []T, fields []SortField[T], page Page) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(page.After)
+	if err != nil {
+		return 0, fmt.Errorf("PageError: invalid cursor: %w", err)
+	}
+	var c pageCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return 0, fmt.Errorf("PageError: invalid cursor: %w", err)
+	}
+	if !slices.Equal(c.Keys, page.OrderBy) || len(c.Values) != len(fields) {
+		return 0, fmt.Errorf("PageError: cursor was created for a different ordering")
+	}
+
+	cursors := make([]func(T) int, len(fields))
+	for i, f := range fields {
+		cursors[i], err = f.cursor(c.Values[i], page.OrderBy[i])
+		if err != nil {
+			return 0, err
+		}
+	}
+	compare := func(record T) int {
+		for _, cursor := range cursors {
+			if c := cursor(record); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+
+	i := sort.Search(len(sorted), func(i int) bool { return compare(sorted[i]) >= 0 })
+	for skipped := 0; skipped < c.Skip && i < len(sorted) && compare(sorted[i]) == 0; skipped++ {
+		i++
+	}
+	return i, nil
+}
+
+// FindPage finds every record matching the query and returns the requested page of them. Records which are equal on
+// every sort key are returned in id order.
+func (c *Collection[T]) FindPage(query Query[T], sorter *Sorter[T], page Page) ([]T, string, error) {
+	found, err := c.Find(query)
+	if err != nil {
+		return nil, "", err
+	}
+	return sorter.Page(found, page)
+}
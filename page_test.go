@@ -0,0 +1,174 @@
+package query_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestSorterPage(t *testing.T) {
+	records := sortRecords()
+	page := smartquery.Page{OrderBy: []smartquery.SortKey{smartquery.Asc("balance")}, Limit: 2}
+
+	out, next, err := testSorter.Page(records, page)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names(out), []string{"b", "d"})
+	assert.Assert(t, next != "")
+
+	out, next, err = testSorter.Page(records, page.Next(next))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names(out), []string{"a", "c"})
+
+	out, next, err = testSorter.Page(records, page.Next(next))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names(out), []string{"e"})
+	assert.Equal(t, next, "")
+
+	// The input is not modified
+	assert.DeepEqual(t, names(records), []string{"a", "b", "c", "d", "e"})
+
+	page.Offset = 3
+	out, next, err = testSorter.Page(records, page)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names(out), []string{"c", "e"})
+	assert.Equal(t, next, "")
+
+	_, _, err = testSorter.Page(records, smartquery.Page{Limit: -1})
+	assert.ErrorContains(t, err, "PageError")
+}
+
+func TestSorterPageHuge(t *testing.T) {
+	records := sortRecords()
+	page := smartquery.Page{OrderBy: []smartquery.SortKey{smartquery.Asc("balance")}, Offset: 1, Limit: math.MaxInt}
+	out, next, err := testSorter.Page(records, page)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names(out), []string{"d", "a", "c", "e"})
+	assert.Equal(t, next, "")
+
+	// The same after a cursor, where the offset and limit are added to a later start
+	page = smartquery.Page{OrderBy: []smartquery.SortKey{smartquery.Asc("balance")}, Limit: 2}
+	_, next, err = testSorter.Page(records, page)
+	assert.NilError(t, err)
+	page = page.Next(next)
+	page.Limit = math.MaxInt
+	out, _, err = testSorter.Page(records, page)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names(out), []string{"a", "c", "e"})
+
+	page.Offset = math.MaxInt
+	out, next, err = testSorter.Page(records, page)
+	assert.NilError(t, err)
+	assert.Equal(t, len(out), 0)
+	assert.Equal(t, next, "")
+}
+
+func TestSorterPageNonFinite(t *testing.T) {
+	score := smartquery.NewField("score", func(f float64) float64 { return f })
+	sorter := smartquery.NewSorter(smartquery.Sortable(score))
+	records := []float64{3, math.NaN(), 1, math.Inf(1), math.NaN(), math.Inf(-1), 2}
+
+	for _, key := range []smartquery.SortKey{smartquery.Asc("score"), smartquery.Desc("score")} {
+		page := smartquery.Page{OrderBy: []smartquery.SortKey{key}, Limit: 2}
+		var all []string
+		for {
+			out, next, err := sorter.Page(records, page)
+			assert.NilError(t, err)
+			for _, f := range out {
+				all = append(all, fmt.Sprint(f))
+			}
+			if next == "" {
+				break
+			}
+			page = page.Next(next)
+		}
+
+		expected := []string{"NaN", "NaN", "-Inf", "1", "2", "3", "+Inf"}
+		if key.Descending {
+			slices.Reverse(expected)
+		}
+		assert.DeepEqual(t, all, expected)
+	}
+}
+
+func TestSorterPageTies(t *testing.T) {
+	// Every record has the same balance, so the cursor has to remember how many of them it already returned
+	records := make([]testStruct, 7)
+	for i := range records {
+		records[i] = testStruct{Name: fmt.Sprint(i), Balance: 1}
+	}
+	page := smartquery.Page{OrderBy: []smartquery.SortKey{smartquery.Desc("balance")}, Limit: 3}
+
+	var all []string
+	for {
+		out, next, err := testSorter.Page(records, page)
+		assert.NilError(t, err)
+		all = append(all, names(out)...)
+		if next == "" {
+			break
+		}
+		page = page.Next(next)
+	}
+	assert.DeepEqual(t, all, []string{"0", "1", "2", "3", "4", "5", "6"})
+}
+
+func TestSorterPageCursorIsKeyset(t *testing.T) {
+	records := sortRecords()
+	page := smartquery.Page{OrderBy: []smartquery.SortKey{smartquery.Asc("stars"), smartquery.Asc("name")}, Limit: 2}
+
+	out, next, err := testSorter.Page(records, page)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names(out), []string{"a", "d"})
+
+	// A record inserted before the cursor does not shift the next page
+	records = append(records, testStruct{Name: "aa", Stars: optional.NewOption(0).AsRef()})
+	out, next, err = testSorter.Page(records, page.Next(next))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names(out), []string{"c", "b"})
+
+	out, next, err = testSorter.Page(records, page.Next(next))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names(out), []string{"e"})
+	assert.Equal(t, next, "")
+
+	_, first, err := testSorter.Page(records, page)
+	assert.NilError(t, err)
+	other := smartquery.Page{OrderBy: []smartquery.SortKey{smartquery.Asc("name")}, After: first}
+	_, _, err = testSorter.Page(records, other)
+	assert.ErrorContains(t, err, "different ordering")
+
+	_, _, err = testSorter.Page(records, page.Next("garbage"))
+	assert.ErrorContains(t, err, "PageError")
+}
+
+func TestPageJSON(t *testing.T) {
+	page := smartquery.Page{OrderBy: []smartquery.SortKey{smartquery.Desc("balance")}, Limit: 10, Offset: 20}
+	raw, err := json.Marshal(page)
+	assert.NilError(t, err)
+	assert.Equal(t, string(raw), `{"order_by":[{"field":"balance","descending":true}],"limit":10,"offset":20}`)
+
+	var decoded smartquery.Page
+	assert.NilError(t, json.Unmarshal(raw, &decoded))
+	assert.DeepEqual(t, decoded, page)
+}
+
+func TestCollectionFindPage(t *testing.T) {
+	c := testCollection()
+	q := nameField.Where(smartquery.ExactString("tester-3").AsRef()).AsRef()
+	page := smartquery.Page{OrderBy: []smartquery.SortKey{smartquery.Desc("balance")}, Limit: 4}
+
+	out, next, err := c.FindPage(q, testSorter, page)
+	assert.NilError(t, err)
+	assert.Equal(t, len(out), 4)
+	assert.Equal(t, out[0].Balance, 93)
+	assert.Equal(t, out[3].Balance, 63)
+
+	out, _, err = c.FindPage(q, testSorter, page.Next(next))
+	assert.NilError(t, err)
+	assert.Equal(t, out[0].Balance, 53)
+}
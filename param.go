@@ -0,0 +1,269 @@
+package query
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/brnsampson/optional"
+)
+
+// ParamQuery is a placeholder in a query template which is replaced by a real query when the template is bound, like a
+// bind variable in a prepared SQL statement. Unlike VarQuery, which looks its variable up every time it is evaluated,
+// a ParamQuery is resolved once by Bind and the bound query is reused for every match. Matching an unbound ParamQuery
+// is an error.
+type ParamQuery[T comparable] struct {
+	name  string
+	build func(value any) (Query[T], error)
+}
+
+// Param creates a ParamQuery which passes the bound value of the named parameter to build. Binding a value which is not
+// a V is an error.
+func Param[T comparable, V any](name string, build func(V) Query[T]) ParamQuery[T] {
+	return ParamQuery[T]{name, func(value any) (Query[T], error) {
+		typed, ok := value.(V)
+		if !ok {
+			var zero V
+			return nil, fmt.Errorf("parameter $%s is a %T, not a %T", name, value, zero)
+		}
+		return build(typed), nil
+	}}
+}
+
+// ExactParam matches values which are equal to the bound parameter.
+func ExactParam[T comparable](name string) ParamQuery[T] {
+	return Param(name, func(value T) Query[T] {
+		return Exact(value).AsRef()
+	})
+}
+
+// ExactStringParam is a template for ExactString.
+func ExactStringParam(name string) ParamQuery[string] {
+	return Param(name, func(value string) Query[string] {
+		return ExactString(value).AsRef()
+	})
+}
+
+// LikeStringParam is a template for LikeString. The pattern is compiled once when the template is bound.
+func LikeStringParam(name string) ParamQuery[string] {
+	return Param(name, func(value string) Query[string] {
+		return LikeString(value).AsRef()
+	})
+}
+
+func (q ParamQuery[T]) AsRef() *ParamQuery[T] {
+	return &q
+}
+
+func (q *ParamQuery[T]) Name() string {
+	return q.name
+}
+
+func (q *ParamQuery[T]) Matches(value T) (bool, error) {
+	return false, fmt.Errorf("QueryError: parameter $%s is not bound", q.name)
+}
+
+func (q *ParamQuery[T]) MatchesOption(value optional.Optional[T]) (bool, error) {
+	return false, fmt.Errorf("QueryError: parameter $%s is not bound", q.name)
+}
+
+func (q *ParamQuery[T]) bind(b *binder) (Query[T], bool) {
+	value, ok := b.params[q.name]
+	if !ok {
+		b.missing = append(b.missing, q.name)
+		return q, false
+	}
+	bound, err := q.build(value)
+	if err != nil {
+		b.errs = append(b.errs, err.Error())
+		return q, false
+	}
+	return bound, true
+}
+
+// Template is a query containing ParamQuery placeholders. Binding it only rebuilds the parts of the query which contain
+// parameters; everything else is shared between the template and all of the queries bound from it.
+type Template[T comparable] struct {
+	query  Query[T]
+	params []string
+}
+
+func NewTemplate[T comparable](query Query[T]) Template[T] {
+	b := binder{}
+	bindQuery(&b, query)
+	return Template[T]{query, b.names()}
+}
+
+func (t Template[T]) AsRef() *Template[T] {
+	return &t
+}
+
+// Params returns the names of the parameters of the template in sorted order.
+func (t *Template[T]) Params() []string {
+	return slices.Clone(t.params)
+}
+
+// Bind replaces every parameter of the template with a query built from its value. It is an error if any parameter is
+// not in params or has a value of the wrong type; parameters which are not used by the template are ignored.
+func (t *Template[T]) Bind(params map[string]any) (Query[T], error) {
+	b := binder{params: params}
+	bound, _ := bindQuery(&b, t.query)
+	if len(b.missing) > 0 {
+		return nil, fmt.Errorf("QueryError: unbound parameters: $%s", strings.Join(b.names(), ", $"))
+	}
+	if len(b.errs) > 0 {
+		return nil, fmt.Errorf("QueryError: invalid parameters: %s", strings.Join(b.errs, "; "))
+	}
+	return bound, nil
+}
+
+// binder collects the problems found while binding so they can all be reported at once.
+type binder struct {
+	params  map[string]any
+	missing []string
+	errs    []string
+}
+
+func (b *binder) names() []string {
+	names := slices.Clone(b.missing)
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// bindable is implemented by queries which contain parameters or other queries. bind returns false if nothing changed,
+// in which case the original query is returned and can be reused as is.
+type bindable[T comparable] interface {
+	bind(b *binder) (Query[T], bool)
+}
+
+func bindQuery[T comparable](b *binder, query Query[T]) (Query[T], bool) {
+	if q, ok := query.(bindable[T]); ok {
+		return q.bind(b)
+	}
+	return query, false
+}
+
+func bindQueries[T comparable](b *binder, queries []Query[T]) ([]Query[T], bool) {
+	var out []Query[T]
+	for i, q := range queries {
+		bound, changed := bindQuery(b, q)
+		if changed && out == nil {
+			out = slices.Clone(queries)
+		}
+		if out != nil {
+			out[i] = bound
+		}
+	}
+	if out == nil {
+		return queries, false
+	}
+	return out, true
+}
+
+func (q *AndQuery[T]) bind(b *binder) (Query[T], bool) {
+	children, changed := bindQueries(b, q.children)
+	if !changed {
+		return q, false
+	}
+	return And(children...).AsRef(), true
+}
+
+func (q *OrQuery[T]) bind(b *binder) (Query[T], bool) {
+	children, changed := bindQueries(b, q.children)
+	if !changed {
+		return q, false
+	}
+	return Or(children...).AsRef(), true
+}
+
+func (q *NotQuery[T]) bind(b *binder) (Query[T], bool) {
+	child, changed := bindQuery(b, q.child)
+	if !changed {
+		return q, false
+	}
+	return Not(child).AsRef(), true
+}
+
+func (p *FieldPredicate[T, F]) bind(b *binder) (Query[T], bool) {
+	query, changed := bindQuery(b, p.query)
+	if !changed {
+		return p, false
+	}
+	return p.field.Where(query).AsRef(), true
+}
+
+func (q *PathQuery[T, F]) bind(b *binder) (Query[T], bool) {
+	query, changed := bindQuery(b, q.query)
+	if !changed {
+		return q, false
+	}
+	tmp := *q
+	tmp.query = query
+	return &tmp, true
+}
+
+func (q *DocQuery[F]) bind(b *binder) (Query[any], bool) {
+	query, changed := bindQuery(b, q.query)
+	if !changed {
+		return q, false
+	}
+	tmp := *q
+	tmp.query = query
+	return &tmp, true
+}
+
+func (q MapQuery[K, V]) bind(b *binder) (MapQuery[K, V], bool) {
+	var out []mapRequirement[K, V]
+	for i, r := range q.requirements {
+		if r.kind != requireValue {
+			continue
+		}
+		query, changed := bindQuery(b, r.query)
+		if changed && out == nil {
+			out = slices.Clone(q.requirements)
+		}
+		if changed {
+			out[i].query = query
+		}
+	}
+	if out == nil {
+		return q, false
+	}
+	return MapQuery[K, V]{out}, true
+}
+
+func (p *MapPredicate[T, K, V]) bind(b *binder) (Query[T], bool) {
+	query, changed := p.query.bind(b)
+	if !changed {
+		return p, false
+	}
+	return p.field.Where(query).AsRef(), true
+}
+
+func (q SliceQuery[E]) bind(b *binder) (SliceQuery[E], bool) {
+	var out []sliceRequirement[E]
+	for i, r := range q.requirements {
+		if r.kind == requireLength {
+			continue
+		}
+		query, changed := bindQuery(b, r.query)
+		if changed && out == nil {
+			out = slices.Clone(q.requirements)
+		}
+		if changed {
+			out[i].query = query
+		}
+	}
+	if out == nil {
+		return q, false
+	}
+	return SliceQuery[E]{out}, true
+}
+
+func (p *SlicePredicate[T, E]) bind(b *binder) (Query[T], bool) {
+	query, changed := p.query.bind(b)
+	if !changed {
+		return p, false
+	}
+	return p.field.Where(query).AsRef(), true
+}
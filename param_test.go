@@ -0,0 +1,58 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestTemplateBind(t *testing.T) {
+	chester := testStruct{Name: "Chester the Tester", Email: optional.NewOption("chester@testing.org").AsRef(), Balance: 42, Stars: optional.None[int]().AsRef()}
+	static := balanceField.Where(smartquery.AtLeast(10).AsRef()).AsRef()
+	template := smartquery.NewTemplate[testStruct](smartquery.And[testStruct](
+		static,
+		nameField.Where(smartquery.LikeStringParam("name").AsRef()).AsRef(),
+		emailField.Where(smartquery.ExactStringParam("email").AsRef()).AsRef(),
+		starsField.Where(smartquery.Not[int](smartquery.ExactParam[int]("stars").AsRef()).AsRef()).AsRef(),
+	).AsRef()).AsRef()
+	assert.DeepEqual(t, template.Params(), []string{"email", "name", "stars"})
+
+	q, err := template.Bind(map[string]any{"name": "Chester%", "email": "chester@testing.org", "stars": 3, "unused": true})
+	assert.NilError(t, err)
+	matches, err := q.Matches(chester)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Bound template did not match!")
+
+	q, err = template.Bind(map[string]any{"name": "Bob%", "email": "chester@testing.org", "stars": 3})
+	assert.NilError(t, err)
+	matches, err = q.Matches(chester)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Bound template matched with a different parameter!")
+
+	// The parts of the template without parameters are shared rather than rebuilt
+	and, ok := q.(*smartquery.AndQuery[testStruct])
+	assert.Assert(t, ok)
+	assert.Equal(t, and.Children()[0], smartquery.Query[testStruct](static))
+
+	_, err = template.Bind(map[string]any{"name": "Chester%"})
+	assert.ErrorContains(t, err, "unbound parameters: $email, $stars")
+
+	_, err = template.Bind(map[string]any{"name": "Chester%", "email": "chester@testing.org", "stars": "3"})
+	assert.ErrorContains(t, err, "parameter $stars is a string, not a int")
+}
+
+func TestUnboundParam(t *testing.T) {
+	q := smartquery.ExactStringParam("tenant").AsRef()
+	_, err := q.Matches("acme")
+	assert.ErrorContains(t, err, "not bound")
+
+	// Templates without parameters bind to themselves
+	plain := smartquery.ExactString("acme").AsRef()
+	template := smartquery.NewTemplate[string](plain)
+	assert.Equal(t, len(template.Params()), 0)
+	bound, err := template.Bind(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, bound, smartquery.Query[string](plain))
+}
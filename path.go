@@ -0,0 +1,180 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/brnsampson/optional"
+)
+
+type pathStepKind int
+
+const (
+	stepField pathStepKind = iota
+	stepDeref
+	stepOption
+)
+
+// pathStep is a single operation needed to walk from a record to the value a PathQuery matches against. Paths are
+// resolved against the static types once when the query is created so that evaluating them only has to follow the
+// steps.
+type pathStep struct {
+	kind   pathStepKind
+	index  []int
+	isNone int
+	unwrap int
+}
+
+// PathQuery applies a query to a value nested inside of a struct, found by following a dotted path of field names such
+// as "Address.City". Pointers and optional.Optional values along the path are followed automatically. If any of them
+// are nil or None, the query is evaluated with MatchesOption against None.
+type PathQuery[T comparable, F comparable] struct {
+	path  string
+	steps []pathStep
+	err   error
+	query Query[F]
+}
+
+// Path creates a PathQuery. Invalid paths (unknown or unexported fields, or a value at the end of the path which is not
+// an F) are reported by Err and returned as an error from every match.
+func Path[T comparable, F comparable](path string, query Query[F]) PathQuery[T, F] {
+	steps, err := compilePath(reflect.TypeFor[T](), reflect.TypeFor[F](), path)
+	return PathQuery[T, F]{path, steps, err, query}
+}
+
+func (q PathQuery[T, F]) AsRef() *PathQuery[T, F] {
+	return &q
+}
+
+func (q *PathQuery[T, F]) Path() string {
+	return q.path
+}
+
+func (q *PathQuery[T, F]) Query() Query[F] {
+	return q.query
+}
+
+func (q *PathQuery[T, F]) Err() error {
+	return q.err
+}
+
+func (q *PathQuery[T, F]) Matches(record T) (bool, error) {
+	return q.MatchesContext(nil, record)
+}
+
+func (q *PathQuery[T, F]) MatchesOption(record optional.Optional[T]) (bool, error) {
+	return q.MatchesOptionContext(nil, record)
+}
+
+func (q *PathQuery[T, F]) MatchesContext(ctx *EvalContext, record T) (bool, error) {
+	if q.err != nil {
+		return false, q.err
+	}
+	value, ok := walkPath(reflect.ValueOf(record), q.steps)
+	if !ok {
+		return EvaluateOption(ctx, q.query, optional.None[F]().AsRef())
+	}
+	if !value.CanInterface() {
+		return false, fmt.Errorf("QueryError: path %s goes through an unexported embedded field", q.path)
+	}
+	return Evaluate(ctx, q.query, value.Interface().(F))
+}
+
+func (q *PathQuery[T, F]) MatchesOptionContext(ctx *EvalContext, record optional.Optional[T]) (bool, error) {
+	if q.err != nil {
+		return false, q.err
+	}
+	if record.IsNone() {
+		// The start of the path is None, so everything along it is as well
+		return EvaluateOption(ctx, q.query, optional.None[F]().AsRef())
+	}
+	return q.MatchesContext(ctx, record.UnsafeUnwrap())
+}
+
+var noneableType = reflect.TypeFor[interface{ IsNone() bool }]()
+
+// optionMethods returns the method indexes of IsNone and UnsafeUnwrap if t looks like an optional.Optional (or one of
+// its implementations), and the type of the wrapped value.
+func optionMethods(t reflect.Type) (isNone int, unwrap int, inner reflect.Type, ok bool) {
+	if !t.Implements(noneableType) {
+		return 0, 0, nil, false
+	}
+	none, _ := t.MethodByName("IsNone")
+	m, found := t.MethodByName("UnsafeUnwrap")
+	if !found || m.Type.NumOut() != 1 {
+		return 0, 0, nil, false
+	}
+	return none.Index, m.Index, m.Type.Out(0), true
+}
+
+// unwrapType adds the steps needed to get from t to a value which is neither a pointer nor an optional.
+func unwrapType(t reflect.Type, steps []pathStep) (reflect.Type, []pathStep) {
+	for {
+		if isNone, unwrap, inner, ok := optionMethods(t); ok {
+			steps = append(steps, pathStep{kind: stepOption, isNone: isNone, unwrap: unwrap})
+			t = inner
+		} else if t.Kind() == reflect.Pointer {
+			steps = append(steps, pathStep{kind: stepDeref})
+			t = t.Elem()
+		} else {
+			return t, steps
+		}
+	}
+}
+
+func compilePath(root, leaf reflect.Type, path string) ([]pathStep, error) {
+	var steps []pathStep
+	t := root
+	for _, name := range strings.Split(path, ".") {
+		t, steps = unwrapType(t, steps)
+		if t.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("QueryError: cannot look up field %s of path %s in non-struct type %s", name, path, t)
+		}
+		f, ok := t.FieldByName(name)
+		if !ok {
+			return nil, fmt.Errorf("QueryError: type %s has no field %s in path %s", t, name, path)
+		} else if !f.IsExported() {
+			return nil, fmt.Errorf("QueryError: field %s in path %s is not exported", name, path)
+		}
+		steps = append(steps, pathStep{kind: stepField, index: f.Index})
+		t = f.Type
+	}
+
+	if t != leaf {
+		t, steps = unwrapType(t, steps)
+	}
+	if t != leaf {
+		return nil, fmt.Errorf("QueryError: path %s has type %s, which cannot be matched by a query on %s", path, t, leaf)
+	}
+	return steps, nil
+}
+
+// walkPath follows the steps from value. ok is false if a nil pointer or None optional was found along the way.
+func walkPath(value reflect.Value, steps []pathStep) (reflect.Value, bool) {
+	var err error
+	for _, s := range steps {
+		switch s.kind {
+		case stepField:
+			// FieldByIndexErr only fails when walking through a nil embedded pointer
+			value, err = value.FieldByIndexErr(s.index)
+			if err != nil {
+				return value, false
+			}
+		case stepDeref:
+			if value.IsNil() {
+				return value, false
+			}
+			value = value.Elem()
+		case stepOption:
+			if (value.Kind() == reflect.Interface || value.Kind() == reflect.Pointer) && value.IsNil() {
+				return value, false
+			}
+			if value.Method(s.isNone).Call(nil)[0].Bool() {
+				return value, false
+			}
+			value = value.Method(s.unwrap).Call(nil)[0]
+		}
+	}
+	return value, true
+}
@@ -0,0 +1,127 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+type testAddress struct {
+	City    string
+	Zip     optional.Optional[string]
+	Country *string
+}
+
+type testCustomer struct {
+	Name     string
+	Address  testAddress
+	Billing  *testAddress
+	Shipping optional.Optional[testAddress]
+	secret   string
+}
+
+func TestPathQuery(t *testing.T) {
+	country := "France"
+	c := testCustomer{
+		Name:     "Chester",
+		Address:  testAddress{City: "Paris", Zip: optional.NewOption("75001").AsRef(), Country: &country},
+		Billing:  nil,
+		Shipping: optional.NewOption(testAddress{City: "Lyon", Zip: optional.None[string]().AsRef()}).AsRef(),
+	}
+	some := optional.NewOption(c)
+	none := optional.None[testCustomer]()
+
+	city := smartquery.Path[testCustomer]("Address.City", smartquery.ExactString("Paris").AsRef())
+	assert.NilError(t, city.Err())
+	matches, err := city.Matches(c)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Path query did not match nested field!")
+
+	matches, err = city.MatchesOption(&some)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Path query did not match nested field of an option!")
+
+	matches, err = city.MatchesOption(&none)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Exact path query matched an option with None value!")
+
+	zip := smartquery.Path[testCustomer]("Address.Zip", smartquery.LikeString("75%").AsRef())
+	matches, err = zip.Matches(c)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Path query did not match optional leaf!")
+
+	countryQuery := smartquery.Path[testCustomer]("Address.Country", smartquery.ExactString("France").AsRef())
+	matches, err = countryQuery.Matches(c)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Path query did not match pointer leaf!")
+
+	shipping := smartquery.Path[testCustomer]("Shipping.City", smartquery.ExactString("Lyon").AsRef())
+	matches, err = shipping.Matches(c)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Path query did not follow optional struct!")
+}
+
+func TestPathQueryNone(t *testing.T) {
+	c := testCustomer{Shipping: optional.NewOption(testAddress{Zip: optional.None[string]().AsRef()}).AsRef()}
+	none := optional.None[testCustomer]()
+
+	// nil pointer in the middle of the path
+	billing := smartquery.Path[testCustomer]("Billing.City", smartquery.NoneString("").AsRef())
+	matches, err := billing.Matches(c)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "None query did not match path through nil pointer!")
+
+	exact := smartquery.Path[testCustomer]("Billing.City", smartquery.ExactString("").AsRef())
+	matches, err = exact.Matches(c)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Exact query matched path through nil pointer!")
+
+	// None at the end of the path
+	zip := smartquery.Path[testCustomer]("Shipping.Zip", smartquery.NoneString("").AsRef())
+	matches, err = zip.Matches(c)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "None query did not match None leaf!")
+
+	// nil Optional interface in the middle of the path
+	c.Shipping = nil
+	matches, err = zip.Matches(c)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "None query did not match path through nil optional!")
+
+	// None record
+	matches, err = zip.MatchesOption(&none)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "None query did not match path from None record!")
+}
+
+func TestPathQueryErrors(t *testing.T) {
+	c := testCustomer{}
+
+	missing := smartquery.Path[testCustomer]("Address.Street", smartquery.ExactString("Main").AsRef())
+	assert.ErrorContains(t, missing.Err(), "no field Street")
+	_, err := missing.Matches(c)
+	assert.ErrorContains(t, err, "QueryError")
+
+	unexported := smartquery.Path[testCustomer]("secret", smartquery.ExactString("").AsRef())
+	assert.ErrorContains(t, unexported.Err(), "not exported")
+
+	notStruct := smartquery.Path[testCustomer]("Name.First", smartquery.ExactString("").AsRef())
+	assert.ErrorContains(t, notStruct.Err(), "non-struct")
+
+	wrongType := smartquery.Path[testCustomer]("Address.City", smartquery.Exact(1).AsRef())
+	assert.ErrorContains(t, wrongType.Err(), "cannot be matched")
+}
+
+func TestPathQueryIgnoresIndexes(t *testing.T) {
+	// An index on a field which happens to share the path's name but extracts something else must not be used to answer
+	// the path query
+	upper := smartquery.NewField("Address.City", func(c testCustomer) string { return "PARIS" })
+	collection := smartquery.NewCollection(smartquery.HashIndex(upper))
+	collection.Insert(testCustomer{Name: "Chester", Address: testAddress{City: "Paris", Zip: optional.None[string]().AsRef()}, Shipping: optional.None[testAddress]().AsRef()})
+
+	found, err := collection.FindIDs(smartquery.Path[testCustomer]("Address.City", smartquery.ExactString("Paris").AsRef()).AsRef())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, []int{0})
+}
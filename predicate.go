@@ -0,0 +1,91 @@
+package query
+
+import (
+	"fmt"
+
+	"github.com/brnsampson/optional"
+)
+
+// PredicateQuery is an escape hatch for logic the built-in queries can't express. It wraps a function, and carries a
+// name so that a query containing it is still more than a black box: the name shows up when the query is rendered with
+// String, in the labels of Instrument and in errors from the function, and is what the predicate is registered and
+// referenced under.
+//
+// Queries can't be serialized yet, so neither can predicates. When they can, a predicate should be written out as its
+// name and read back as a Ref to a Registry it was registered in under that name, since the function itself can't be
+// written out.
+type PredicateQuery[T comparable] struct {
+	name   string
+	value  func(T) (bool, error)
+	option func(optional.Optional[T]) (bool, error)
+	reads  []string
+	cost   float64
+}
+
+// Predicate creates a query from a function on plain values. Like the other queries, None never matches.
+func Predicate[T comparable](name string, fn func(T) (bool, error)) PredicateQuery[T] {
+	return PredicateQuery[T]{name: name, value: fn}
+}
+
+// OptionPredicate creates a query from a function which also decides what to do with None. Plain values are passed to
+// it as Some.
+func OptionPredicate[T comparable](name string, fn func(optional.Optional[T]) (bool, error)) PredicateQuery[T] {
+	return PredicateQuery[T]{name: name, option: fn}
+}
+
+func (q PredicateQuery[T]) AsRef() *PredicateQuery[T] {
+	return &q
+}
+
+// Reading returns a copy of the predicate which declares the fields of the record it reads, for Dependencies. Without
+// it a predicate is assumed to read the whole record.
+func (q PredicateQuery[T]) Reading(fields ...string) PredicateQuery[T] {
+	q.reads = append([]string{}, fields...)
+	return q
+}
+
+// Cost returns the cost set with WithCost, or the default cost for queries without a hint.
+func (q *PredicateQuery[T]) Cost() float64 {
+	if q.cost == 0 {
+		return defaultCost
+	}
+	return q.cost
+}
+
+// WithCost returns a copy of the predicate with a cost hint for Optimize. Plain equality has a cost of 1.
+func (q PredicateQuery[T]) WithCost(cost float64) PredicateQuery[T] {
+	q.cost = cost
+	return q
+}
+
+func (q *PredicateQuery[T]) Name() string {
+	return q.name
+}
+
+func (q *PredicateQuery[T]) String() string {
+	return q.name + "()"
+}
+
+func (q *PredicateQuery[T]) Matches(value T) (bool, error) {
+	if q.option != nil {
+		return q.wrap(q.option(optional.NewOption(value).AsRef()))
+	}
+	return q.wrap(q.value(value))
+}
+
+func (q *PredicateQuery[T]) MatchesOption(value optional.Optional[T]) (bool, error) {
+	if q.option != nil {
+		return q.wrap(q.option(value))
+	}
+	if value.IsNone() {
+		return false, nil
+	}
+	return q.wrap(q.value(value.UnsafeUnwrap()))
+}
+
+func (q *PredicateQuery[T]) wrap(matched bool, err error) (bool, error) {
+	if err != nil {
+		return false, fmt.Errorf("QueryError: predicate %s: %w", q.name, err)
+	}
+	return matched, nil
+}
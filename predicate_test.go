@@ -0,0 +1,60 @@
+package query_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestPredicate(t *testing.T) {
+	even := smartquery.Predicate("even", func(v int) (bool, error) { return v%2 == 0, nil })
+	assert.Equal(t, even.AsRef().Name(), "even")
+	assert.Equal(t, even.AsRef().String(), "even()")
+
+	matches, err := even.Matches(4)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Predicate did not match!")
+
+	some := optional.NewOption(3)
+	matches, err = even.MatchesOption(&some)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Predicate matched a value it rejects!")
+
+	none := optional.None[int]()
+	matches, err = even.MatchesOption(&none)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Predicate matched option with None value!")
+
+	// The option variant decides what None means itself
+	missingOrEven := smartquery.OptionPredicate("missing_or_even", func(v optional.Optional[int]) (bool, error) {
+		return v.IsNone() || v.UnsafeUnwrap()%2 == 0, nil
+	})
+	matches, err = missingOrEven.MatchesOption(&none)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Option predicate did not see None!")
+	matches, err = missingOrEven.Matches(3)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Option predicate matched a value it rejects!")
+
+	// Predicates mix with the rest of the query tree, and errors say which predicate failed
+	boom := errors.New("boom")
+	failing := smartquery.Predicate("fails", func(s string) (bool, error) { return false, boom })
+	q := nameField.Where(smartquery.Or[string](
+		smartquery.Predicate("short", func(s string) (bool, error) { return len(s) < 5, nil }).AsRef(),
+		failing.AsRef(),
+	).AsRef()).AsRef()
+
+	s := testStruct{Name: "Chet", Email: optional.None[string]().AsRef(), Stars: optional.None[int]().AsRef()}
+	matches, err = q.Matches(s)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Predicate in a combinator did not match!")
+
+	s.Name = strings.Repeat("x", 10)
+	_, err = q.Matches(s)
+	assert.ErrorContains(t, err, "predicate fails: boom")
+	assert.Assert(t, errors.Is(err, boom))
+}
@@ -13,12 +13,16 @@ type MatchType int
 const (
 	// Matching operations defined. These are currently implemented for individual values, but could be extended to slices
 	// of values as noted:
-	MatchAlways MatchType = iota // This ALWAYS matches. It is true if S = S ∪ S which is always true.
-	MatchNone                    // True if ⦰ = S
-	MatchAny                     // True if ⦰ != S
-	MatchSome                    // True if ⦰ != S1 ∩ S2. TODO: implement this! Until we support slices in queries this is the same as Exact though...
-	MatchExact                   // True if ⦰ = S1 𝚫 S2
-	MatchLike                    // Only valid for strings: perform
+	MatchAlways       MatchType = iota // This ALWAYS matches. It is true if S = S ∪ S which is always true.
+	MatchNone                          // True if ⦰ = S
+	MatchAny                           // True if ⦰ != S
+	MatchSome                          // True if ⦰ != S1 ∩ S2. TODO: implement this! Until we support slices in queries this is the same as Exact though...
+	MatchExact                         // True if ⦰ = S1 𝚫 S2
+	MatchLike                          // Only valid for strings: perform
+	MatchGlob                          // Only valid for strings: shell style glob patterns, see GlobString
+	MatchEditDistance                  // Only valid for strings: at most some number of edits away, see WithinEdits
+	MatchJaroWinkler                   // Only valid for strings: Jaro-Winkler similarity of at least some threshold, see JaroWinkler
+	MatchTrigram                       // Only valid for strings: trigram similarity of at least some threshold, see Trigram
 )
 
 type Query[T comparable] interface {
@@ -28,61 +32,70 @@ type Query[T comparable] interface {
 
 func Always[T comparable]() FieldQuery[T] {
 	tmp := optional.None[T]()
-	return FieldQuery[T]{MatchAlways, &tmp}
+	return NewQuery[T](MatchAlways, &tmp)
 }
 
 func None[T comparable](match T) FieldQuery[T] {
 	tmp := optional.NewOption[T](match)
-	return FieldQuery[T]{MatchNone, &tmp}
+	return NewQuery[T](MatchNone, &tmp)
 }
 
 func Any[T comparable](match T) FieldQuery[T] {
 	tmp := optional.NewOption[T](match)
-	return FieldQuery[T]{MatchAny, &tmp}
+	return NewQuery[T](MatchAny, &tmp)
 }
 
 func Exact[T comparable](match T) FieldQuery[T] {
 	tmp := optional.NewOption[T](match)
-	return FieldQuery[T]{MatchExact, &tmp}
+	return NewQuery[T](MatchExact, &tmp)
 }
 
 func Like[T comparable](match T) FieldQuery[T] {
 	tmp := optional.NewOption[T](match)
-	return FieldQuery[T]{MatchLike, &tmp}
+	return NewQuery[T](MatchLike, &tmp)
 }
 
 func AlwaysString() StringQuery {
 	tmp := optional.None[string]()
-	return StringQuery{MatchAlways, &tmp}
+	return NewStringQuery(MatchAlways, &tmp)
 }
 
 func NoneString(match string) StringQuery {
 	tmp := optional.NewOption(match)
-	return StringQuery{MatchNone, &tmp}
+	return NewStringQuery(MatchNone, &tmp)
 }
 
 func AnyString(match string) StringQuery {
 	tmp := optional.NewOption(match)
-	return StringQuery{MatchAny, &tmp}
+	return NewStringQuery(MatchAny, &tmp)
 }
 
 func ExactString(match string) StringQuery {
 	tmp := optional.NewOption(match)
-	return StringQuery{MatchExact, &tmp}
+	return NewStringQuery(MatchExact, &tmp)
 }
 
 func LikeString(match string) StringQuery {
 	tmp := optional.NewOption(match)
-	return StringQuery{MatchLike, &tmp}
+	return NewStringQuery(MatchLike, &tmp)
 }
 
 type FieldQuery[T comparable] struct {
 	criteria MatchType
 	value    optional.Optional[T]
+	// Snapshot of value taken when the query is built so that matching doesn't have to unwrap it every time
+	some bool
+	test T
 }
 
+// NewQuery creates a query from a copy of value, so changing value afterwards does not change the query.
 func NewQuery[T comparable, O optional.Optional[T]](matchType MatchType, value O) FieldQuery[T] {
-	return FieldQuery[T]{matchType, value}
+	q := FieldQuery[T]{criteria: matchType, value: value.Clone()}
+	if !q.value.IsNone() {
+		q.some = true
+		q.test = q.value.UnsafeUnwrap()
+	}
+	return q
 }
 
 func (q FieldQuery[T]) AsRef() *FieldQuery[T] {
@@ -90,12 +103,8 @@ func (q FieldQuery[T]) AsRef() *FieldQuery[T] {
 }
 
 func (q *FieldQuery[T]) Matches(value T) (bool, error) {
-	none := q.value.IsNone()
-	tmp := q.value.Clone()
-	var val T
-	if !none {
-		val = tmp.UnsafeUnwrap()
-	}
+	none := !q.some
+	val := q.test
 
 	c := q.criteria
 	if c == MatchAlways {
@@ -117,17 +126,15 @@ func (q *FieldQuery[T]) Matches(value T) (bool, error) {
 	} else if c == MatchLike {
 		// Not supported!
 		return false, fmt.Errorf("QueryError: cannot perform MatchLike matches on generic type. Use StringQuery instead.")
+	} else if c == MatchGlob {
+		return false, fmt.Errorf("QueryError: cannot perform MatchGlob matches on generic type. Use StringQuery instead.")
 	}
 	return false, fmt.Errorf("QueryError: unsupported matching strategy: %d", c)
 }
 
 func (q *FieldQuery[T]) MatchesOption(value optional.Optional[T]) (bool, error) {
-	none := q.value.IsNone()
-	tmp := q.value.Clone()
-	var val T
-	if !none {
-		val = tmp.UnsafeUnwrap()
-	}
+	none := !q.some
+	val := q.test
 
 	c := q.criteria
 	otherMatchNone := value.IsNone()
@@ -140,7 +147,10 @@ func (q *FieldQuery[T]) MatchesOption(value optional.Optional[T]) (bool, error)
 	} else if (c == MatchNone && otherMatchAny) || (c == MatchAny && otherMatchNone) {
 		return false, nil
 	} else if c == MatchExact {
-		other, _ := value.Clone().Unwrap()
+		var other T
+		if otherMatchAny {
+			other = value.UnsafeUnwrap()
+		}
 		if none && otherMatchAny {
 			// Just here so we never try to compare val if it is not initialized
 			return false, nil
@@ -157,17 +167,82 @@ func (q *FieldQuery[T]) MatchesOption(value optional.Optional[T]) (bool, error)
 	} else if c == MatchLike {
 		// Not supported!
 		return false, fmt.Errorf("QueryError: cannot perform MatchLike matches on generic type. Use StringQuery instead.")
+	} else if c == MatchGlob {
+		return false, fmt.Errorf("QueryError: cannot perform MatchGlob matches on generic type. Use StringQuery instead.")
 	}
 	return false, fmt.Errorf("QueryError: unsupported matching strategy: %d", c)
 }
 
 type StringQuery struct {
-	criteria MatchType
-	value    optional.Optional[string]
+	criteria      MatchType
+	value         optional.Optional[string]
+	pattern       *regexp.Regexp
+	globstar      bool
+	normalization Normalization
+	// Snapshot of value, see FieldQuery
+	some bool
+	test string
+	// Settings of the fuzzy strategies, see fuzzy.go
+	distance  int
+	threshold float64
+	runes     []rune
+	trigrams  []trigram
 }
 
+// NewStringQuery creates a query from a copy of value, so changing value afterwards does not change the query.
 func NewStringQuery(matchType MatchType, value optional.Optional[string]) StringQuery {
-	return StringQuery{matchType, value}
+	q := StringQuery{criteria: matchType, value: value.Clone()}
+	q.snapshot()
+	// Compile the pattern once up front. If it is invalid leave it nil so the error is returned when matching.
+	q.pattern, _ = q.compile()
+	return q
+}
+
+// snapshot must be called whenever value changes.
+func (q *StringQuery) snapshot() {
+	q.some = !q.value.IsNone()
+	q.test = ""
+	if q.some {
+		q.test = q.value.UnsafeUnwrap()
+	}
+	q.runes, q.trigrams = nil, nil
+	if q.criteria == MatchEditDistance || q.criteria == MatchJaroWinkler {
+		q.runes = []rune(q.test)
+	} else if q.criteria == MatchTrigram {
+		q.trigrams = trigrams(q.test, nil)
+	}
+}
+
+// compile returns the regexp for MatchLike and MatchGlob queries, or nil for everything else.
+func (q *StringQuery) compile() (*regexp.Regexp, error) {
+	if q.value.IsNone() {
+		return nil, nil
+	}
+	if q.criteria == MatchLike {
+		return likePattern(q.value.UnsafeUnwrap())
+	} else if q.criteria == MatchGlob {
+		return globPattern(q.value.UnsafeUnwrap(), q.globstar)
+	}
+	return nil, nil
+}
+
+// likePattern converts a MatchLike pattern into a regexp. MatchLike supports two wildcards, % for multiple characters
+// and _ for a single char. We support this by converting those to the regexp equivilants (.* and . respectively)
+func likePattern(pattern string) (*regexp.Regexp, error) {
+	tmp := strings.ReplaceAll(pattern, "%", ".*")
+	tmp = strings.ReplaceAll(tmp, "_", ".")
+	return regexp.Compile(tmp)
+}
+
+func (q *StringQuery) matchPattern(value string) (bool, error) {
+	if q.pattern != nil {
+		return q.pattern.MatchString(value), nil
+	}
+	re, err := q.compile()
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(value), nil
 }
 
 func (q StringQuery) AsRef() *StringQuery {
@@ -175,10 +250,10 @@ func (q StringQuery) AsRef() *StringQuery {
 }
 
 func (q *StringQuery) Matches(value string) (bool, error) {
+	value = q.normalization.apply(value)
 	c := q.criteria
-	tmp := q.value.Clone()
-	test, err := tmp.Unwrap()
-	if err != nil {
+	test := q.test
+	if !q.some {
 		// q.value is MatchNone!
 		if c == MatchAlways {
 			return true, nil
@@ -190,7 +265,7 @@ func (q *StringQuery) Matches(value string) (bool, error) {
 		} else if c == MatchExact {
 			// value is a non-option type, so it is always MatchAny
 			return false, nil
-		} else if c == MatchLike {
+		} else if c == MatchLike || c == MatchGlob || c.fuzzy() {
 			// Not sure why this would come up, but I guess a MatchLike match of MatchNone matches nothing?
 			return false, nil
 		} else {
@@ -213,27 +288,20 @@ func (q *StringQuery) Matches(value string) (bool, error) {
 		} else {
 			return false, nil
 		}
-	} else if c == MatchLike {
-		// MatchLike supports two wildcards, % for multiple characters and _ for a single char.
-		// We support this by converting those to the regexp equivilants (.* and . respectively)
-		tmp := strings.ReplaceAll(test, "%", ".*")
-		tmp = strings.ReplaceAll(tmp, "_", ".")
-		matches, err := regexp.MatchString(tmp, value)
-		if err != nil {
-			return false, err
-		}
-		return matches, nil
+	} else if c == MatchLike || c == MatchGlob {
+		return q.matchPattern(value)
+	} else if c.fuzzy() {
+		return q.matchFuzzy(value)
 	}
 	return false, fmt.Errorf("QueryError: unsupported matching strategy: %d", c)
 }
 
 func (q *StringQuery) MatchesOption(value optional.Optional[string]) (bool, error) {
 	c := q.criteria
-	tmp := q.value.Clone()
-	test, err := tmp.Unwrap()
+	test := q.test
 
 	otherMatchNone := value.IsNone()
-	if err != nil {
+	if !q.some {
 		// q.value is MatchNone!
 		if c == MatchAlways {
 			return true, nil
@@ -243,7 +311,7 @@ func (q *StringQuery) MatchesOption(value optional.Optional[string]) (bool, erro
 			return !otherMatchNone, nil
 		} else if c == MatchExact {
 			return otherMatchNone, nil
-		} else if c == MatchLike {
+		} else if c == MatchLike || c == MatchGlob || c.fuzzy() {
 			// Not sure why this would come up, but I guess a MatchLike match of MatchNone matches against MatchNone and nothing else?
 			return otherMatchNone, nil
 		} else {
@@ -252,8 +320,11 @@ func (q *StringQuery) MatchesOption(value optional.Optional[string]) (bool, erro
 	}
 
 	// The case of q.value being MatchNone is handled above
-	other, err := value.Clone().Unwrap()
-	if err != nil {
+	var other string
+	if !otherMatchNone {
+		other = q.normalization.apply(value.UnsafeUnwrap())
+	}
+	if otherMatchNone {
 		// The case of value is MatchNone and q.value is MatchAny
 		if c == MatchAlways {
 			return true, nil
@@ -263,7 +334,7 @@ func (q *StringQuery) MatchesOption(value optional.Optional[string]) (bool, erro
 			return false, nil
 		} else if c == MatchExact {
 			return false, nil
-		} else if c == MatchLike {
+		} else if c == MatchLike || c == MatchGlob || c.fuzzy() {
 			// MatchNone has no content that could possibly match
 			return false, nil
 		} else {
@@ -285,16 +356,27 @@ func (q *StringQuery) MatchesOption(value optional.Optional[string]) (bool, erro
 		} else {
 			return false, nil
 		}
-	} else if c == MatchLike {
-		// MatchLike supports two wildcards, % for multiple characters and _ for a single char.
-		// We support this by converting those to the regexp equivilants (.* and . respectively)
-		tmp := strings.ReplaceAll(test, "%", ".*")
-		tmp = strings.ReplaceAll(tmp, "_", ".")
-		matches, err := regexp.MatchString(tmp, other)
-		if err != nil {
-			return false, err
-		}
-		return matches, nil
+	} else if c == MatchLike || c == MatchGlob {
+		return q.matchPattern(other)
+	} else if c.fuzzy() {
+		return q.matchFuzzy(other)
 	}
 	return false, fmt.Errorf("QueryError: unsupported matching strategy: %d", c)
 }
+
+// MatchesContext compares MatchExact queries with the collation of the context, if it has one. Everything else is the
+// same as Matches.
+func (q *StringQuery) MatchesContext(ctx *EvalContext, value string) (bool, error) {
+	collation := ctx.Collation()
+	if collation == nil || q.criteria != MatchExact || !q.some {
+		return q.Matches(value)
+	}
+	return collation.Compare(q.test, q.normalization.apply(value)) == 0, nil
+}
+
+func (q *StringQuery) MatchesOptionContext(ctx *EvalContext, value optional.Optional[string]) (bool, error) {
+	if value.IsNone() {
+		return q.MatchesOption(value)
+	}
+	return q.MatchesContext(ctx, value.UnsafeUnwrap())
+}
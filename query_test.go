@@ -721,3 +721,62 @@ func TestLikeStringQuery(t *testing.T) {
 	assert.NilError(t, err)
 	assert.Assert(t, matches, "Like[pattern] query did not match option with the same pattern!")
 }
+
+func TestQueryCopiesValue(t *testing.T) {
+	value := optional.NewOption(42)
+	q := smartquery.NewQuery[int](smartquery.MatchExact, &value)
+	value = optional.NewOption(7)
+
+	matches, err := q.Matches(42)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Query should keep the value it was built with!")
+	matches, err = q.Matches(7)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Query should not see changes to the value it was built from!")
+}
+
+func TestQueryAllocations(t *testing.T) {
+	glob, err := smartquery.GlobString("chester*")
+	assert.NilError(t, err)
+	strings := map[string]smartquery.StringQuery{
+		"always":  smartquery.AlwaysString(),
+		"none":    smartquery.NoneString("chester"),
+		"any":     smartquery.AnyString("chester"),
+		"exact":   smartquery.ExactString("chester"),
+		"like":    smartquery.LikeString("ches%"),
+		"glob":    glob,
+		"edits":   smartquery.WithinEdits("chester", 2),
+		"jaro":    smartquery.JaroWinkler("chester", 0.8),
+		"trigram": smartquery.Trigram("chester", 0.3),
+	}
+	fields := map[string]smartquery.FieldQuery[int]{
+		"always": smartquery.Always[int](),
+		"none":   smartquery.None(42),
+		"any":    smartquery.Any(42),
+		"exact":  smartquery.Exact(42),
+	}
+
+	someString := optional.NewOption("chester").AsRef()
+	noneString := optional.None[string]().AsRef()
+	for name, q := range strings {
+		allocs := testing.AllocsPerRun(100, func() {
+			q.Matches("chester")
+			q.Matches("tester")
+			q.MatchesOption(someString)
+			q.MatchesOption(noneString)
+		})
+		assert.Equal(t, allocs, 0.0, "StringQuery %s allocated when matching", name)
+	}
+
+	someInt := optional.NewOption(42).AsRef()
+	noneInt := optional.None[int]().AsRef()
+	for name, q := range fields {
+		allocs := testing.AllocsPerRun(100, func() {
+			q.Matches(42)
+			q.Matches(7)
+			q.MatchesOption(someInt)
+			q.MatchesOption(noneInt)
+		})
+		assert.Equal(t, allocs, 0.0, "FieldQuery %s allocated when matching", name)
+	}
+}
@@ -0,0 +1,131 @@
+package query
+
+import (
+	"cmp"
+
+	"github.com/brnsampson/optional"
+)
+
+// RangeQuery matches values which fall between an optional lower and an optional upper bound. A None bound is
+// unbounded on that side. Like the other queries, a None value never falls inside of a range. By default NaN is
+// unordered, so it is never inside of a range and a NaN bound matches nothing; use OrderNaN to change that.
+type RangeQuery[T cmp.Ordered] struct {
+	lower          optional.Optional[T]
+	upper          optional.Optional[T]
+	lowerInclusive bool
+	upperInclusive bool
+	nans           NaNOrder
+}
+
+// NaNOrder says where NaN goes when comparing floats in a range.
+type NaNOrder int
+
+const (
+	NaNUnordered NaNOrder = iota // NaN is not ordered relative to anything, so comparisons with it are always false
+	NaNSmallest                  // NaN is equal to NaN and smaller than every other value, including -Inf. Same as cmp.Compare.
+	NaNLargest                   // NaN is equal to NaN and larger than every other value, including +Inf
+)
+
+func LessThan[T cmp.Ordered](bound T) RangeQuery[T] {
+	return RangeQuery[T]{optional.None[T]().AsRef(), optional.NewOption(bound).AsRef(), false, false, NaNUnordered}
+}
+
+func AtMost[T cmp.Ordered](bound T) RangeQuery[T] {
+	return RangeQuery[T]{optional.None[T]().AsRef(), optional.NewOption(bound).AsRef(), false, true, NaNUnordered}
+}
+
+func GreaterThan[T cmp.Ordered](bound T) RangeQuery[T] {
+	return RangeQuery[T]{optional.NewOption(bound).AsRef(), optional.None[T]().AsRef(), false, false, NaNUnordered}
+}
+
+func AtLeast[T cmp.Ordered](bound T) RangeQuery[T] {
+	return RangeQuery[T]{optional.NewOption(bound).AsRef(), optional.None[T]().AsRef(), true, false, NaNUnordered}
+}
+
+// Between matches values in the closed range [lower, upper].
+func Between[T cmp.Ordered](lower, upper T) RangeQuery[T] {
+	return RangeQuery[T]{optional.NewOption(lower).AsRef(), optional.NewOption(upper).AsRef(), true, true, NaNUnordered}
+}
+
+func NewRangeQuery[T cmp.Ordered](lower, upper optional.Optional[T], lowerInclusive, upperInclusive bool) RangeQuery[T] {
+	return RangeQuery[T]{lower, upper, lowerInclusive, upperInclusive, NaNUnordered}
+}
+
+// OrderNaN returns a copy of the query which orders NaN as given. For example GreaterThan(0.0).OrderNaN(NaNLargest)
+// treats NaN like an overflow and matches it. This only makes a difference for float values.
+func (q RangeQuery[T]) OrderNaN(order NaNOrder) RangeQuery[T] {
+	q.nans = order
+	return q
+}
+
+func (q RangeQuery[T]) AsRef() *RangeQuery[T] {
+	return &q
+}
+
+func (q *RangeQuery[T]) Matches(value T) (bool, error) {
+	if q.nans != NaNUnordered {
+		return q.matchesOrdered(value), nil
+	}
+
+	// Comparisons are written so that anything unordered (i.e. NaN) falls outside of every bound
+	if !q.lower.IsNone() {
+		bound := q.lower.UnsafeUnwrap()
+		if q.lowerInclusive && !(value >= bound) {
+			return false, nil
+		} else if !q.lowerInclusive && !(value > bound) {
+			return false, nil
+		}
+	}
+	if !q.upper.IsNone() {
+		bound := q.upper.UnsafeUnwrap()
+		if q.upperInclusive && !(value <= bound) {
+			return false, nil
+		} else if !q.upperInclusive && !(value < bound) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (q *RangeQuery[T]) matchesOrdered(value T) bool {
+	if !q.lower.IsNone() {
+		c := compareNaN(value, q.lower.UnsafeUnwrap(), q.nans)
+		if c < 0 || (c == 0 && !q.lowerInclusive) {
+			return false
+		}
+	}
+	if !q.upper.IsNone() {
+		c := compareNaN(value, q.upper.UnsafeUnwrap(), q.nans)
+		if c > 0 || (c == 0 && !q.upperInclusive) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareNaN is cmp.Compare with NaN placed according to order.
+func compareNaN[T cmp.Ordered](a, b T, order NaNOrder) int {
+	aNaN, bNaN := a != a, b != b
+	if aNaN || bNaN {
+		if aNaN && bNaN {
+			return 0
+		}
+		// One side is NaN. It is the smaller one if NaN sorts first.
+		c := 1
+		if order == NaNSmallest {
+			c = -1
+		}
+		if bNaN {
+			c = -c
+		}
+		return c
+	}
+	return cmp.Compare(a, b)
+}
+
+func (q *RangeQuery[T]) MatchesOption(value optional.Optional[T]) (bool, error) {
+	if value.IsNone() {
+		return false, nil
+	}
+	return q.Matches(value.UnsafeUnwrap())
+}
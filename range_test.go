@@ -0,0 +1,58 @@
+package query_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestRangeQuery(t *testing.T) {
+	cases := []struct {
+		name    string
+		query   smartquery.RangeQuery[int]
+		value   int
+		matches bool
+	}{
+		{"LessThan below", smartquery.LessThan(10), 9, true},
+		{"LessThan bound", smartquery.LessThan(10), 10, false},
+		{"AtMost bound", smartquery.AtMost(10), 10, true},
+		{"AtMost above", smartquery.AtMost(10), 11, false},
+		{"GreaterThan above", smartquery.GreaterThan(10), 11, true},
+		{"GreaterThan bound", smartquery.GreaterThan(10), 10, false},
+		{"AtLeast bound", smartquery.AtLeast(10), 10, true},
+		{"AtLeast below", smartquery.AtLeast(10), 9, false},
+		{"Between lower", smartquery.Between(1, 3), 1, true},
+		{"Between upper", smartquery.Between(1, 3), 3, true},
+		{"Between outside", smartquery.Between(1, 3), 4, false},
+		{"Unbounded", smartquery.NewRangeQuery[int](optional.None[int]().AsRef(), optional.None[int]().AsRef(), false, false), -100, true},
+	}
+
+	for _, c := range cases {
+		some := optional.NewOption(c.value)
+		matches, err := c.query.Matches(c.value)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, "%s: wrong result for value", c.name)
+
+		matches, err = c.query.MatchesOption(&some)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, "%s: wrong result for option with Any value", c.name)
+
+		none := optional.None[int]()
+		matches, err = c.query.MatchesOption(&none)
+		assert.NilError(t, err)
+		assert.Assert(t, !matches, "%s: range query matched option with None value!", c.name)
+	}
+
+	words := smartquery.Between("b", "d")
+	matches, err := words.Matches("cat")
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "String range did not match a string between the bounds!")
+
+	nan := smartquery.LessThan(math.Inf(1))
+	matches, err = nan.Matches(math.NaN())
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "NaN should never fall inside of a range!")
+}
@@ -0,0 +1,276 @@
+package query
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/brnsampson/optional"
+)
+
+// Registry holds named queries so they can be defined once and reused through Ref. Every time a name is registered it
+// gets a new version. Refs either follow the latest version of a name, so redefining it changes every query using it,
+// or are pinned to one version so they only change when the reference itself is updated.
+//
+// A Registry is safe to use from multiple goroutines. Registering a query which would make a cycle of references, or
+// which refers to a name or version that doesn't exist, is an error and leaves the registry unchanged.
+type Registry[T comparable] struct {
+	lock    sync.RWMutex
+	queries map[string][]Query[T]
+}
+
+func NewRegistry[T comparable]() *Registry[T] {
+	return &Registry[T]{queries: make(map[string][]Query[T])}
+}
+
+// Register adds a new version of the named query and returns its version number, starting from 1.
+func (r *Registry[T]) Register(name string, query Query[T]) (int, error) {
+	versions, err := r.Load(map[string]Query[T]{name: query})
+	if err != nil {
+		return 0, err
+	}
+	return versions[name], nil
+}
+
+// Load registers a set of queries at once, so they may refer to each other in any order. Either all of them are
+// registered or, if any of them is invalid, none are. It returns the new version of each name.
+func (r *Registry[T]) Load(queries map[string]Query[T]) (map[string]int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	names := make([]string, 0, len(queries))
+	for name := range queries {
+		if name == "" {
+			return nil, fmt.Errorf("QueryError: registered queries must have a name")
+		}
+		names = append(names, name)
+	}
+	// Check in a fixed order so the same mistake always gives the same error
+	slices.Sort(names)
+
+	for _, name := range names {
+		r.queries[name] = append(r.queries[name], queries[name])
+	}
+	versions := make(map[string]int, len(names))
+	for _, name := range names {
+		if err := r.check(queries[name], []string{name}); err != nil {
+			for _, n := range names {
+				r.queries[n] = r.queries[n][:len(r.queries[n])-1]
+				if len(r.queries[n]) == 0 {
+					delete(r.queries, n)
+				}
+			}
+			return nil, err
+		}
+		versions[name] = len(r.queries[name])
+	}
+	return versions, nil
+}
+
+// check follows every reference to this registry from query, with path holding the names it went through to get
+// there. The lock must be held. References to other registries aren't followed, since that would need their locks too.
+func (r *Registry[T]) check(query any, path []string) error {
+	switch q := query.(type) {
+	case *RefQuery[T]:
+		if q.registry != r {
+			return nil
+		}
+		if i := slices.Index(path, q.name); i >= 0 {
+			return fmt.Errorf("QueryError: cycle of query references: %s -> %s", strings.Join(path[i:], " -> "), q.name)
+		}
+		target, err := r.lookup(q.name, q.version)
+		if err != nil {
+			return err
+		}
+		return r.check(target, append(path, q.name))
+	case composite:
+		for _, child := range q.subqueries() {
+			if err := r.check(child, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// composite is implemented by every query which is built from other queries, so that check can find references
+// anywhere inside of a query. The subqueries are returned as any since a field or path query doesn't query the same
+// type as its children.
+type composite interface {
+	subqueries() []any
+}
+
+func (q *AndQuery[T]) subqueries() []any {
+	return queriesToAny(q.children)
+}
+
+func (q *OrQuery[T]) subqueries() []any {
+	return queriesToAny(q.children)
+}
+
+func (q *NotQuery[T]) subqueries() []any {
+	return []any{q.child}
+}
+
+func (q *instrumentedQuery[T]) subqueries() []any {
+	return []any{q.query}
+}
+
+func (p *FieldPredicate[T, F]) subqueries() []any {
+	return []any{p.query}
+}
+
+func (q *PathQuery[T, F]) subqueries() []any {
+	return []any{q.query}
+}
+
+func (q *DocQuery[F]) subqueries() []any {
+	return []any{q.query}
+}
+
+func (p *MapPredicate[T, K, V]) subqueries() []any {
+	var out []any
+	for _, req := range p.query.requirements {
+		if req.query != nil {
+			out = append(out, req.query)
+		}
+	}
+	return out
+}
+
+func (p *SlicePredicate[T, E]) subqueries() []any {
+	var out []any
+	for _, req := range p.query.requirements {
+		if req.query != nil {
+			out = append(out, req.query)
+		}
+	}
+	return out
+}
+
+func queriesToAny[T comparable](queries []Query[T]) []any {
+	out := make([]any, len(queries))
+	for i, q := range queries {
+		out[i] = q
+	}
+	return out
+}
+
+// lookup finds a version of a query, where version 0 is the latest. The lock must be held.
+func (r *Registry[T]) lookup(name string, version int) (Query[T], error) {
+	versions, ok := r.queries[name]
+	if !ok {
+		return nil, fmt.Errorf("QueryError: no query named %s", name)
+	}
+	if version == 0 {
+		return versions[len(versions)-1], nil
+	}
+	if version < 0 || version > len(versions) {
+		return nil, fmt.Errorf("QueryError: query %s has no version %d", name, version)
+	}
+	return versions[version-1], nil
+}
+
+// Get returns the latest version of the named query and its version number.
+func (r *Registry[T]) Get(name string) (Query[T], int, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	versions, ok := r.queries[name]
+	if !ok {
+		return nil, 0, false
+	}
+	return versions[len(versions)-1], len(versions), true
+}
+
+func (r *Registry[T]) GetVersion(name string, version int) (Query[T], bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	q, err := r.lookup(name, version)
+	return q, err == nil && version > 0
+}
+
+// Names returns the registered names in sorted order.
+func (r *Registry[T]) Names() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	names := make([]string, 0, len(r.queries))
+	for name := range r.queries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Ref creates a reference to the latest version of the named query. The name is looked up every time the reference
+// is evaluated, and evaluating a reference to an unknown name is an error.
+func (r *Registry[T]) Ref(name string) RefQuery[T] {
+	return RefQuery[T]{r, name, 0}
+}
+
+// RefVersion creates a reference to one version of the named query.
+func (r *Registry[T]) RefVersion(name string, version int) RefQuery[T] {
+	return RefQuery[T]{r, name, version}
+}
+
+// RefQuery matches whatever the query it refers to matches. See Registry.
+type RefQuery[T comparable] struct {
+	registry *Registry[T]
+	name     string
+	version  int
+}
+
+func (q RefQuery[T]) AsRef() *RefQuery[T] {
+	return &q
+}
+
+func (q *RefQuery[T]) Name() string {
+	return q.name
+}
+
+// Version returns the version the reference is pinned to, or 0 if it follows the latest version.
+func (q *RefQuery[T]) Version() int {
+	return q.version
+}
+
+func (q *RefQuery[T]) String() string {
+	if q.version == 0 {
+		return "@" + q.name
+	}
+	return fmt.Sprintf("@%s@%d", q.name, q.version)
+}
+
+// Resolve returns the query the reference currently points to.
+func (q *RefQuery[T]) Resolve() (Query[T], error) {
+	q.registry.lock.RLock()
+	defer q.registry.lock.RUnlock()
+
+	return q.registry.lookup(q.name, q.version)
+}
+
+func (q *RefQuery[T]) Matches(value T) (bool, error) {
+	return q.MatchesContext(nil, value)
+}
+
+func (q *RefQuery[T]) MatchesOption(value optional.Optional[T]) (bool, error) {
+	return q.MatchesOptionContext(nil, value)
+}
+
+func (q *RefQuery[T]) MatchesContext(ctx *EvalContext, value T) (bool, error) {
+	target, err := q.Resolve()
+	if err != nil {
+		return false, err
+	}
+	return Evaluate(ctx, target, value)
+}
+
+func (q *RefQuery[T]) MatchesOptionContext(ctx *EvalContext, value optional.Optional[T]) (bool, error) {
+	target, err := q.Resolve()
+	if err != nil {
+		return false, err
+	}
+	return EvaluateOption(ctx, target, value)
+}
@@ -0,0 +1,122 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := smartquery.NewRegistry[testStruct]()
+	rich := registry.Ref("rich")
+	version, err := registry.Register("rich", balanceField.Where(smartquery.AtLeast(100).AsRef()).AsRef())
+	assert.NilError(t, err)
+	assert.Equal(t, version, 1)
+
+	_, err = registry.Register("active_customer", smartquery.And[testStruct](
+		rich.AsRef(),
+		emailField.Where(smartquery.AnyString("").AsRef()).AsRef(),
+	).AsRef())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, registry.Names(), []string{"active_customer", "rich"})
+
+	s := testStruct{Name: "Chester", Email: optional.NewOption("chester@testing.org").AsRef(), Balance: 50, Stars: optional.None[int]().AsRef()}
+	active := registry.Ref("active_customer")
+	matches, err := active.Matches(s)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Reference matched before the definition it uses allowed it!")
+
+	// Redefining a name is seen by references to the latest version but not by pinned ones
+	pinned := registry.RefVersion("rich", 1)
+	version, err = registry.Register("rich", balanceField.Where(smartquery.AtLeast(10).AsRef()).AsRef())
+	assert.NilError(t, err)
+	assert.Equal(t, version, 2)
+
+	matches, err = active.Matches(s)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Reference did not see the new version of a query it uses!")
+
+	matches, err = pinned.Matches(s)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Pinned reference saw a newer version!")
+
+	_, latest, ok := registry.Get("rich")
+	assert.Assert(t, ok)
+	assert.Equal(t, latest, 2)
+	_, ok = registry.GetVersion("rich", 3)
+	assert.Assert(t, !ok)
+
+	missing := registry.Ref("missing")
+	_, err = missing.Matches(s)
+	assert.ErrorContains(t, err, "no query named missing")
+	_, err = registry.RefVersion("rich", 9).AsRef().Matches(s)
+	assert.ErrorContains(t, err, "no version 9")
+}
+
+func TestRegistryCycles(t *testing.T) {
+	registry := smartquery.NewRegistry[int]()
+	_, err := registry.Register("a", smartquery.AtLeast(1).AsRef())
+	assert.NilError(t, err)
+	_, err = registry.Register("b", smartquery.Not[int](registry.Ref("a").AsRef()).AsRef())
+	assert.NilError(t, err)
+
+	_, err = registry.Register("a", smartquery.Or[int](smartquery.Exact(0).AsRef(), registry.Ref("b").AsRef()).AsRef())
+	assert.ErrorContains(t, err, "cycle of query references: a -> b -> a")
+
+	// The failed registration left the registry unchanged
+	_, version, _ := registry.Get("a")
+	assert.Equal(t, version, 1)
+
+	_, err = registry.Register("self", registry.Ref("self").AsRef())
+	assert.ErrorContains(t, err, "self -> self")
+	assert.DeepEqual(t, registry.Names(), []string{"a", "b"})
+
+	// Pinning b doesn't help since b follows the latest a, but pinning a itself does
+	_, err = registry.Register("a", smartquery.Or[int](smartquery.Exact(0).AsRef(), registry.RefVersion("b", 1).AsRef()).AsRef())
+	assert.ErrorContains(t, err, "cycle")
+	_, err = registry.Register("c", smartquery.And[int](registry.RefVersion("a", 1).AsRef(), registry.Ref("b").AsRef()).AsRef())
+	assert.NilError(t, err)
+
+	// Load accepts queries which refer to each other in any order, but not cycles between them
+	versions, err := registry.Load(map[string]smartquery.Query[int]{
+		"x": registry.Ref("y").AsRef(),
+		"y": smartquery.LessThan(5).AsRef(),
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, versions, map[string]int{"x": 1, "y": 1})
+
+	_, err = registry.Load(map[string]smartquery.Query[int]{
+		"p": registry.Ref("q").AsRef(),
+		"q": registry.Ref("p").AsRef(),
+	})
+	assert.ErrorContains(t, err, "cycle")
+	assert.DeepEqual(t, registry.Names(), []string{"a", "b", "c", "x", "y"})
+
+	_, err = registry.Register("z", registry.Ref("nope").AsRef())
+	assert.ErrorContains(t, err, "no query named nope")
+}
+
+func TestRegistryNestedCycles(t *testing.T) {
+	// Fields of an int which are ints themselves can refer back to a query on the record
+	registry := smartquery.NewRegistry[int]()
+	half := smartquery.NewField("half", func(n int) int { return n / 2 })
+	_, err := registry.Register("a", half.Where(registry.Ref("a").AsRef()).AsRef())
+	assert.ErrorContains(t, err, "cycle of query references: a -> a")
+
+	_, err = registry.Register("b", smartquery.Not[int](half.Where(registry.Ref("nope").AsRef()).AsRef()).AsRef())
+	assert.ErrorContains(t, err, "no query named nope")
+
+	_, err = registry.Register("small", smartquery.LessThan(10).AsRef())
+	assert.NilError(t, err)
+	_, err = registry.Register("c", half.Where(registry.Ref("small").AsRef()).AsRef())
+	assert.NilError(t, err)
+	_, err = registry.Register("small", half.Where(registry.Ref("c").AsRef()).AsRef())
+	assert.ErrorContains(t, err, "cycle of query references: small -> c -> small")
+
+	digits := smartquery.NewSliceField("digits", func(n int) []int { return []int{n % 10, n / 10 % 10} })
+	_, err = registry.Register("d", digits.Where(smartquery.AnyElement[int](registry.Ref("d").AsRef())).AsRef())
+	assert.ErrorContains(t, err, "d -> d")
+	assert.DeepEqual(t, registry.Names(), []string{"c", "small"})
+}
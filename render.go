@@ -0,0 +1,87 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Queries render as a tree of their parts, so that logs and error messages show what a query does rather than the
+// address of a struct. And, Or and Not render like calls of their constructors, queries on fields and paths as the
+// field or path followed by the query on it, and leaves as their matching strategy and value:
+//
+//	And(balance: exact(100), Not(email: like("%@example.com")), chester(), @vip)
+//
+// Named predicates render as name() and references as @name, so custom logic stays visible inside of a tree. Queries
+// which have no String method of their own render as their type.
+
+var matchTypeNames = []string{"always", "none", "any", "some", "exact", "like", "glob", "edits", "jarowinkler", "trigram"}
+
+func (c MatchType) String() string {
+	if int(c) >= 0 && int(c) < len(matchTypeNames) {
+		return matchTypeNames[c]
+	}
+	return fmt.Sprintf("MatchType(%d)", int(c))
+}
+
+// describe renders a query for String, falling back to its type.
+func describe(query any) string {
+	if s, ok := query.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", query), "*")
+}
+
+func describeChildren[T comparable](name string, children []Query[T]) string {
+	parts := make([]string, len(children))
+	for i, child := range children {
+		parts[i] = describe(child)
+	}
+	return name + "(" + strings.Join(parts, ", ") + ")"
+}
+
+func (q *AndQuery[T]) String() string {
+	return describeChildren("And", q.children)
+}
+
+func (q *OrQuery[T]) String() string {
+	return describeChildren("Or", q.children)
+}
+
+func (q *NotQuery[T]) String() string {
+	return "Not(" + describe(q.child) + ")"
+}
+
+func (q *instrumentedQuery[T]) String() string {
+	return describe(q.query)
+}
+
+func (p *FieldPredicate[T, F]) String() string {
+	return p.field.name + ": " + describe(p.query)
+}
+
+func (q *PathQuery[T, F]) String() string {
+	return q.path + ": " + describe(q.query)
+}
+
+func (q *DocQuery[F]) String() string {
+	return q.path + ": " + describe(q.query)
+}
+
+func (q *FieldQuery[T]) String() string {
+	if !q.some {
+		return q.criteria.String() + "(None)"
+	}
+	return fmt.Sprintf("%s(%v)", q.criteria, q.test)
+}
+
+func (q *StringQuery) String() string {
+	if !q.some {
+		return q.criteria.String() + "(None)"
+	}
+	if q.criteria == MatchEditDistance {
+		return fmt.Sprintf("%s(%q, %d)", q.criteria, q.test, q.distance)
+	} else if q.criteria == MatchJaroWinkler || q.criteria == MatchTrigram {
+		return fmt.Sprintf("%s(%q, %v)", q.criteria, q.test, q.threshold)
+	}
+	return fmt.Sprintf("%s(%q)", q.criteria, q.test)
+}
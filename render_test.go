@@ -0,0 +1,41 @@
+package query_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestQueryString(t *testing.T) {
+	registry := smartquery.NewRegistry[testStruct]()
+	chester := smartquery.Predicate("chester", func(s testStruct) (bool, error) { return s.Name == "Chester", nil })
+	q := smartquery.And[testStruct](
+		balanceField.Where(smartquery.Exact(100).AsRef()).AsRef(),
+		smartquery.Not[testStruct](emailField.Where(smartquery.LikeString("%@example.com").AsRef()).AsRef()).AsRef(),
+		smartquery.Or[testStruct](chester.AsRef(), registry.Ref("vip").AsRef()).AsRef(),
+	)
+	assert.Equal(t, q.String(), `And(balance: exact(100), Not(email: like("%@example.com")), Or(chester(), @vip))`)
+	assert.Equal(t, fmt.Sprint(q.AsRef()), q.String())
+
+	// Instrumenting a query doesn't change how it renders
+	instrumented := smartquery.Instrument[testStruct]("q", q.AsRef(), smartquery.NewRuntimeStats())
+	assert.Equal(t, fmt.Sprint(instrumented), q.String())
+
+	cases := []struct {
+		query    fmt.Stringer
+		rendered string
+	}{
+		{smartquery.Path[testCustomer]("Address.City", smartquery.ExactString("Paris").AsRef()).AsRef(), `Address.City: exact("Paris")`},
+		{smartquery.DocPath("order.id", smartquery.Any(0).AsRef()).AsRef(), "order.id: any(0)"},
+		{smartquery.NewStringQuery(smartquery.MatchLike, optional.None[string]().AsRef()).AsRef(), "like(None)"},
+		{smartquery.WithinEdits("kitten", 2).AsRef(), `edits("kitten", 2)`},
+		{smartquery.Trigram("word", 0.3).AsRef(), `trigram("word", 0.3)`},
+		{starsField.Where(smartquery.AtLeast(3).AsRef()).AsRef(), "stars: query.RangeQuery[int]"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.query.String(), c.rendered)
+	}
+}
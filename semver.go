@@ -0,0 +1,352 @@
+package query
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/brnsampson/optional"
+)
+
+// Version is a parsed semantic version (https://semver.org). Build metadata is kept for String but, as the spec says,
+// ignored for ordering.
+type Version struct {
+	Major, Minor, Patch uint64
+	Prerelease          []string
+	Build               string
+}
+
+// ParseVersion parses a full version like "1.2.3", "v1.2.3-rc.1" or "1.2.3+build.5".
+func ParseVersion(s string) (Version, error) {
+	p, err := parsePartialVersion(s)
+	if err != nil {
+		return Version{}, err
+	}
+	if p.parts < 3 {
+		return Version{}, fmt.Errorf("QueryError: invalid version %q: expected major.minor.patch", s)
+	}
+	return p.Version, nil
+}
+
+// Compare returns -1, 0 or 1 depending on whether v has lower, equal or higher precedence than other.
+func (v Version) Compare(other Version) int {
+	if c := cmp.Compare(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+
+	// A release has higher precedence than any of its prereleases
+	if len(v.Prerelease) == 0 || len(other.Prerelease) == 0 {
+		return cmp.Compare(len(other.Prerelease), len(v.Prerelease))
+	}
+	for i := 0; i < min(len(v.Prerelease), len(other.Prerelease)); i++ {
+		if c := comparePrerelease(v.Prerelease[i], other.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(v.Prerelease), len(other.Prerelease))
+}
+
+// comparePrerelease compares numeric identifiers numerically and anything else in ASCII order. Numeric identifiers
+// are lower than alphanumeric ones.
+func comparePrerelease(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	if aErr == nil && bErr == nil {
+		return cmp.Compare(an, bn)
+	} else if aErr == nil {
+		return -1
+	} else if bErr == nil {
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+func (v Version) sameRelease(other Version) bool {
+	return v.Major == other.Major && v.Minor == other.Minor && v.Patch == other.Patch
+}
+
+// partialVersion is a version in a range, where trailing parts may be missing or wildcards (x, X or *). parts is the
+// number of parts given before the first missing or wildcard one.
+type partialVersion struct {
+	Version
+	parts int
+}
+
+func parsePartialVersion(s string) (partialVersion, error) {
+	orig := s
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "=")
+	var p partialVersion
+	s, p.Build, _ = strings.Cut(s, "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+
+	numbers := strings.Split(s, ".")
+	if len(numbers) > 3 || s == "" {
+		return p, fmt.Errorf("QueryError: invalid version %q", orig)
+	}
+	fields := []*uint64{&p.Major, &p.Minor, &p.Patch}
+	wildcard := false
+	for i, n := range numbers {
+		if n == "x" || n == "X" || n == "*" {
+			wildcard = true
+			continue
+		}
+		if wildcard {
+			return p, fmt.Errorf("QueryError: invalid version %q: numbers can't follow a wildcard", orig)
+		}
+		value, err := strconv.ParseUint(n, 10, 64)
+		if err != nil {
+			return p, fmt.Errorf("QueryError: invalid version %q: %q is not a number", orig, n)
+		} else if hasLeadingZero(n) {
+			return p, fmt.Errorf("QueryError: invalid version %q: %q has a leading zero", orig, n)
+		}
+		*fields[i] = value
+		p.parts++
+	}
+
+	if hasPre {
+		if p.parts < 3 {
+			return p, fmt.Errorf("QueryError: invalid version %q: only full versions can have a prerelease", orig)
+		}
+		for _, id := range strings.Split(pre, ".") {
+			if id == "" || strings.Trim(id, "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ-") != "" {
+				return p, fmt.Errorf("QueryError: invalid version %q: bad prerelease identifier %q", orig, id)
+			} else if strings.Trim(id, "0123456789") == "" && hasLeadingZero(id) {
+				return p, fmt.Errorf("QueryError: invalid version %q: numeric prerelease identifier %q has a leading zero", orig, id)
+			}
+			p.Prerelease = append(p.Prerelease, id)
+		}
+	}
+	return p, nil
+}
+
+// hasLeadingZero reports whether a number has a leading zero, which SemVer doesn't allow.
+func hasLeadingZero(n string) bool {
+	return len(n) > 1 && n[0] == '0'
+}
+
+// next returns the lowest version above every version matching the partial version, as a "-0" prerelease so that
+// prereleases of it are excluded as well. For example 1.2 gives 1.3.0-0.
+func (p partialVersion) next() Version {
+	if p.parts == 1 {
+		return Version{Major: p.Major + 1, Prerelease: []string{"0"}}
+	}
+	return Version{Major: p.Major, Minor: p.Minor + 1, Prerelease: []string{"0"}}
+}
+
+type versionComparator struct {
+	op      string
+	version Version
+}
+
+func (c versionComparator) matches(v Version) bool {
+	r := v.Compare(c.version)
+	switch c.op {
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	}
+	return r == 0
+}
+
+// VersionQuery matches strings holding semantic versions against an npm style range. Strings which are not valid
+// versions and None never match.
+type VersionQuery struct {
+	expr string
+	// sets are OR'd together and the comparators in each set are AND'd
+	sets [][]versionComparator
+}
+
+// VersionRange parses an npm or cargo style range. A range is one or more comparator sets separated by ||, and a set
+// matches if all of its space separated comparators do. Comparators are:
+//
+//	1.2.3, =1.2.3     exactly 1.2.3
+//	1.2, 1.2.x        any 1.2 version
+//	*, x, ""          any version
+//	>=1.2, >1.2, <2, <=2.1
+//	^1.4              compatible with 1.4: >=1.4.0 <2.0.0. For 0.x versions the first non-zero part is fixed instead.
+//	~0.3.1            patch updates only: >=0.3.1 <0.4.0
+//	1.2 - 2.3         inclusive range: >=1.2.0 <2.4.0
+//
+// Like npm, prereleases only match if a comparator in the same set has a prerelease of the same major.minor.patch,
+// so ">=1.2.3-beta.1" matches "1.2.3-rc.1" but not "1.3.0-alpha".
+func VersionRange(expr string) (VersionQuery, error) {
+	q := VersionQuery{expr: expr}
+	for _, set := range strings.Split(expr, "||") {
+		comparators, err := parseComparatorSet(set)
+		if err != nil {
+			return VersionQuery{}, fmt.Errorf("QueryError: invalid version range %q: %w", expr, err)
+		}
+		q.sets = append(q.sets, comparators)
+	}
+	return q, nil
+}
+
+func parseComparatorSet(set string) ([]versionComparator, error) {
+	tokens := strings.Fields(set)
+	// Allow a space between an operator and its version, like ">= 1.2"
+	for i := 0; i < len(tokens)-1; i++ {
+		if strings.Trim(tokens[i], "<>=^~") == "" {
+			tokens = append(tokens[:i], append([]string{tokens[i] + tokens[i+1]}, tokens[i+2:]...)...)
+		}
+	}
+
+	if len(tokens) == 3 && tokens[1] == "-" {
+		lower, err := parsePartialVersion(tokens[0])
+		if err != nil {
+			return nil, err
+		}
+		upper, err := parsePartialVersion(tokens[2])
+		if err != nil {
+			return nil, err
+		}
+		out := []versionComparator{{">=", lower.Version}}
+		if upper.parts == 3 {
+			return append(out, versionComparator{"<=", upper.Version}), nil
+		} else if upper.parts > 0 {
+			return append(out, versionComparator{"<", upper.next()}), nil
+		}
+		return out, nil
+	}
+
+	var out []versionComparator
+	for _, token := range tokens {
+		comparators, err := parseComparator(token)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, comparators...)
+	}
+	if len(out) == 0 {
+		// An empty set matches any release
+		out = append(out, versionComparator{">=", Version{}})
+	}
+	return out, nil
+}
+
+func parseComparator(token string) ([]versionComparator, error) {
+	op := token[:len(token)-len(strings.TrimLeft(token, "<>=^~"))]
+	p, err := parsePartialVersion(token[len(op):])
+	if err != nil {
+		return nil, err
+	}
+	v := p.Version
+
+	switch op {
+	case "^":
+		if p.parts == 0 {
+			return []versionComparator{{">=", Version{}}}, nil
+		}
+		upper := Version{Major: v.Major + 1, Prerelease: []string{"0"}}
+		if v.Major == 0 && p.parts >= 2 {
+			if v.Minor != 0 || p.parts == 2 {
+				upper = Version{Minor: v.Minor + 1, Prerelease: []string{"0"}}
+			} else {
+				upper = Version{Patch: v.Patch + 1, Prerelease: []string{"0"}}
+			}
+		}
+		return []versionComparator{{">=", v}, {"<", upper}}, nil
+	case "~", "~>":
+		if p.parts == 0 {
+			return []versionComparator{{">=", Version{}}}, nil
+		}
+		return []versionComparator{{">=", v}, {"<", p.next()}}, nil
+	case ">=":
+		return []versionComparator{{">=", v}}, nil
+	case "<":
+		return []versionComparator{{"<", v}}, nil
+	case ">":
+		if p.parts == 0 {
+			// Nothing is greater than every version
+			return []versionComparator{{"<", Version{}}}, nil
+		} else if p.parts < 3 {
+			// Unlike an upper bound this must not be a prerelease, since that would let prereleases of it match
+			lower := p.next()
+			lower.Prerelease = nil
+			return []versionComparator{{">=", lower}}, nil
+		}
+		return []versionComparator{{">", v}}, nil
+	case "<=":
+		if p.parts == 0 {
+			return []versionComparator{{">=", Version{}}}, nil
+		} else if p.parts < 3 {
+			return []versionComparator{{"<", p.next()}}, nil
+		}
+		return []versionComparator{{"<=", v}}, nil
+	case "", "=":
+		if p.parts == 0 {
+			return []versionComparator{{">=", Version{}}}, nil
+		} else if p.parts < 3 {
+			return []versionComparator{{">=", v}, {"<", p.next()}}, nil
+		}
+		return []versionComparator{{"=", v}}, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+func (q VersionQuery) AsRef() *VersionQuery {
+	return &q
+}
+
+func (q *VersionQuery) String() string {
+	return q.expr
+}
+
+func (q *VersionQuery) Matches(value string) (bool, error) {
+	v, err := ParseVersion(value)
+	if err != nil {
+		return false, nil
+	}
+	for _, set := range q.sets {
+		if matchesComparatorSet(set, v) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (q *VersionQuery) MatchesOption(value optional.Optional[string]) (bool, error) {
+	if value.IsNone() {
+		return false, nil
+	}
+	return q.Matches(value.UnsafeUnwrap())
+}
+
+func matchesComparatorSet(set []versionComparator, v Version) bool {
+	for _, c := range set {
+		if !c.matches(v) {
+			return false
+		}
+	}
+	if len(v.Prerelease) == 0 {
+		return true
+	}
+	for _, c := range set {
+		if len(c.version.Prerelease) > 0 && c.version.sameRelease(v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package query_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestVersionRange(t *testing.T) {
+	cases := []struct {
+		expr    string
+		value   string
+		matches bool
+	}{
+		{">=1.2 <2", "1.2.0", true},
+		{">=1.2 <2", "1.10.3", true},
+		{">=1.2 <2", "2.0.0", false},
+		{">=1.2 <2", "1.1.9", false},
+		{">= 1.2 < 2", "1.5.0", true},
+		{"^1.4", "1.9.0", true},
+		{"^1.4", "1.3.9", false},
+		{"^1.4", "2.0.0", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.4", false},
+		{"^0.0", "0.0.9", true},
+		{"~0.3.1", "0.3.5", true},
+		{"~0.3.1", "0.4.0", false},
+		{"~0.3.1", "0.3.0", false},
+		{"~1", "1.9.9", true},
+		{"1.x", "1.2.3", true},
+		{"1.x", "10.0.0", false},
+		{"1", "10.0.0", false},
+		{"1.2.3", "1.2.3+build.7", true},
+		{"1.2.3", "1.2.4", false},
+		{"*", "3.4.5", true},
+		{"", "0.0.1", true},
+		{">1.2", "1.2.9", false},
+		{">1.2", "1.3.0", true},
+		{"<=1.2", "1.2.9", true},
+		{"<=1.2", "1.3.0", false},
+		{"1.2 - 2.3", "2.3.9", true},
+		{"1.2 - 2.3", "2.4.0", false},
+		{"1.2.3 - 2.3.4", "2.3.4", true},
+		{"1.2.3 - 2.3.4", "2.3.5", false},
+		{"<1 || >=3", "0.9.0", true},
+		{"<1 || >=3", "2.0.0", false},
+		{"<1 || >=3", "3.1.0", true},
+		{"v1.2.x", "v1.2.7", true},
+
+		// Prereleases only match comparators with a prerelease of the same release
+		{">=1.2.3-beta.1", "1.2.3-rc.1", true},
+		{">=1.2.3-beta.1", "1.2.3-alpha", false},
+		{">=1.2.3-beta.1", "1.3.0-alpha", false},
+		{">=1.2.3-beta.1", "1.3.0", true},
+		{"^1.4", "1.5.0-rc.1", false},
+		{"<2", "2.0.0-rc.1", false},
+		{">1.2", "1.3.0-alpha", false},
+		{">=1.3.0", "1.3.0-alpha", false},
+		{"~1.2", "1.3.0-alpha", false},
+		{"<=1.2", "1.3.0-alpha", false},
+		{"*", "1.0.0-alpha", false},
+
+		// Values which are not versions never match
+		{"*", "latest", false},
+		{"*", "1.2", false},
+		{"1.2.3", "01.2.3", false},
+		{"*", "1.02.3", false},
+		{"*", "1.2.00", false},
+		{"*", "1.2.3-beta.01", false},
+		{"*", "0.0.0", true},
+		{">=1.2.3-0", "1.2.3-0a", true},
+	}
+
+	for _, c := range cases {
+		q, err := smartquery.VersionRange(c.expr)
+		assert.NilError(t, err, c.expr)
+
+		matches, err := q.Matches(c.value)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, "%q against %s", c.expr, c.value)
+
+		some := optional.NewOption(c.value)
+		matches, err = q.MatchesOption(&some)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, "%q against %s", c.expr, c.value)
+
+		none := optional.None[string]()
+		matches, err = q.MatchesOption(&none)
+		assert.NilError(t, err)
+		assert.Assert(t, !matches, "%q: version query matched option with None value!", c.expr)
+	}
+
+	for _, expr := range []string{"1.2.3.4", ">=a", "1.x.3", "1.2-beta", "1.2.3-beta..1", "=>1.2", "01.2", ">=1.02.3", "1.2.3-01"} {
+		_, err := smartquery.VersionRange(expr)
+		assert.ErrorContains(t, err, "invalid version range", expr)
+	}
+}
+
+func TestVersionOrder(t *testing.T) {
+	// The example ordering from the semver spec
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11",
+		"1.0.0-rc.1", "1.0.0", "2.0.0", "2.1.0", "2.1.1", "10.0.0",
+	}
+	var versions []smartquery.Version
+	for i := len(ordered) - 1; i >= 0; i-- {
+		v, err := smartquery.ParseVersion(ordered[i])
+		assert.NilError(t, err)
+		versions = append(versions, v)
+	}
+	slices.SortFunc(versions, smartquery.Version.Compare)
+	for i, v := range versions {
+		assert.Equal(t, v.String(), ordered[i])
+	}
+
+	a, _ := smartquery.ParseVersion("1.0.0+a")
+	b, _ := smartquery.ParseVersion("1.0.0+b")
+	assert.Equal(t, a.Compare(b), 0, "Build metadata was used for ordering!")
+}
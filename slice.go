@@ -0,0 +1,154 @@
+package query
+
+import (
+	"github.com/brnsampson/optional"
+)
+
+type sliceRequirementKind int
+
+const (
+	requireAnyElement sliceRequirementKind = iota
+	requireAllElements
+	requireNoElement
+	requireLength
+)
+
+type sliceRequirement[E comparable] struct {
+	kind     sliceRequirementKind
+	query    Query[E]
+	min, max int
+}
+
+// SliceQuery matches slices by quantifying a query over their elements and by their length. A SliceQuery matches when
+// all of its requirements are met.
+//
+// Like maps, slices are not comparable, so a SliceQuery cannot be a Query[[]E] and optional.Optional cannot hold a
+// slice. Use SliceField to apply one to a slice valued field of a record; NewOptionSliceField handles slices which may
+// be missing (None). A None slice never matches.
+type SliceQuery[E comparable] struct {
+	requirements []sliceRequirement[E]
+}
+
+// AnyElement matches slices with at least one element matching the query. Empty slices never match.
+func AnyElement[E comparable](query Query[E]) SliceQuery[E] {
+	return SliceQuery[E]{[]sliceRequirement[E]{{kind: requireAnyElement, query: query}}}
+}
+
+// AllElements matches slices where every element matches the query. Empty slices always match.
+func AllElements[E comparable](query Query[E]) SliceQuery[E] {
+	return SliceQuery[E]{[]sliceRequirement[E]{{kind: requireAllElements, query: query}}}
+}
+
+// NoElement matches slices where no element matches the query. Empty slices always match.
+func NoElement[E comparable](query Query[E]) SliceQuery[E] {
+	return SliceQuery[E]{[]sliceRequirement[E]{{kind: requireNoElement, query: query}}}
+}
+
+// LenBetween matches slices whose length is in the closed range [min, max].
+func LenBetween[E comparable](min, max int) SliceQuery[E] {
+	return SliceQuery[E]{[]sliceRequirement[E]{{kind: requireLength, min: min, max: max}}}
+}
+
+// And returns a SliceQuery with the requirements of q and all of the others.
+func (q SliceQuery[E]) And(others ...SliceQuery[E]) SliceQuery[E] {
+	requirements := append([]sliceRequirement[E]{}, q.requirements...)
+	for _, o := range others {
+		requirements = append(requirements, o.requirements...)
+	}
+	return SliceQuery[E]{requirements}
+}
+
+func (q SliceQuery[E]) Matches(s []E) (bool, error) {
+	return q.MatchesContext(nil, s)
+}
+
+func (q SliceQuery[E]) MatchesContext(ctx *EvalContext, s []E) (bool, error) {
+	for _, r := range q.requirements {
+		matched, err := r.matches(ctx, s)
+		if err != nil {
+			return false, err
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (r sliceRequirement[E]) matches(ctx *EvalContext, s []E) (bool, error) {
+	if r.kind == requireLength {
+		return len(s) >= r.min && len(s) <= r.max, nil
+	}
+
+	for _, e := range s {
+		matched, err := Evaluate(ctx, r.query, e)
+		if err != nil {
+			return false, err
+		}
+		if matched && r.kind == requireAnyElement {
+			return true, nil
+		} else if !matched && r.kind == requireAllElements {
+			return false, nil
+		} else if matched && r.kind == requireNoElement {
+			return false, nil
+		}
+	}
+	// Nothing decided the result early: Any found no match, All and No found no counterexample
+	return r.kind != requireAnyElement, nil
+}
+
+// SliceField is a slice valued field of a record. Slices are not comparable, so they cannot be used with Field.
+type SliceField[T comparable, E comparable] struct {
+	name  string
+	value func(T) ([]E, bool)
+}
+
+func NewSliceField[T comparable, E comparable](name string, value func(T) []E) SliceField[T, E] {
+	return SliceField[T, E]{name, func(record T) ([]E, bool) { return value(record), true }}
+}
+
+// NewOptionSliceField creates a SliceField for a slice which may be None. value returns false for None.
+func NewOptionSliceField[T comparable, E comparable](name string, value func(T) ([]E, bool)) SliceField[T, E] {
+	return SliceField[T, E]{name, value}
+}
+
+func (f SliceField[T, E]) Name() string {
+	return f.name
+}
+
+// Where creates a query which matches records whose slice field matches the given SliceQuery.
+func (f SliceField[T, E]) Where(query SliceQuery[E]) SlicePredicate[T, E] {
+	return SlicePredicate[T, E]{f, query}
+}
+
+type SlicePredicate[T comparable, E comparable] struct {
+	field SliceField[T, E]
+	query SliceQuery[E]
+}
+
+func (p SlicePredicate[T, E]) AsRef() *SlicePredicate[T, E] {
+	return &p
+}
+
+func (p *SlicePredicate[T, E]) Matches(record T) (bool, error) {
+	return p.MatchesContext(nil, record)
+}
+
+func (p *SlicePredicate[T, E]) MatchesOption(record optional.Optional[T]) (bool, error) {
+	return p.MatchesOptionContext(nil, record)
+}
+
+func (p *SlicePredicate[T, E]) MatchesContext(ctx *EvalContext, record T) (bool, error) {
+	s, ok := p.field.value(record)
+	if !ok {
+		return false, nil
+	}
+	return p.query.MatchesContext(ctx, s)
+}
+
+func (p *SlicePredicate[T, E]) MatchesOptionContext(ctx *EvalContext, record optional.Optional[T]) (bool, error) {
+	if record.IsNone() {
+		return false, nil
+	}
+	return p.MatchesContext(ctx, record.UnsafeUnwrap())
+}
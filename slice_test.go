@@ -0,0 +1,87 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestSliceQuery(t *testing.T) {
+	skus := []string{"X-100", "Y-200", "X-300"}
+	xPrefix := smartquery.LikeString("X%").AsRef()
+	zPrefix := smartquery.LikeString("Z%").AsRef()
+
+	cases := []struct {
+		name    string
+		query   smartquery.SliceQuery[string]
+		value   []string
+		matches bool
+	}{
+		{"any", smartquery.AnyElement[string](xPrefix), skus, true},
+		{"any none match", smartquery.AnyElement[string](zPrefix), skus, false},
+		{"any empty", smartquery.AnyElement[string](xPrefix), nil, false},
+		{"all", smartquery.AllElements[string](smartquery.LikeString("%00").AsRef()), skus, true},
+		{"all counterexample", smartquery.AllElements[string](xPrefix), skus, false},
+		{"all empty", smartquery.AllElements[string](xPrefix), nil, true},
+		{"no element", smartquery.NoElement[string](zPrefix), skus, true},
+		{"no element counterexample", smartquery.NoElement[string](xPrefix), skus, false},
+		{"no element empty", smartquery.NoElement[string](xPrefix), []string{}, true},
+		{"len", smartquery.LenBetween[string](1, 3), skus, true},
+		{"len too long", smartquery.LenBetween[string](0, 2), skus, false},
+		{"and", smartquery.AnyElement[string](xPrefix).And(smartquery.LenBetween[string](4, 10)), skus, false},
+	}
+
+	for _, c := range cases {
+		matches, err := c.query.Matches(c.value)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, c.name)
+	}
+
+	broken := smartquery.AnyElement[int](smartquery.Like(1).AsRef())
+	_, err := broken.Matches([]int{1})
+	assert.ErrorContains(t, err, "QueryError")
+}
+
+type orderStruct struct {
+	ID    int
+	Lines *[]string
+}
+
+func TestSliceField(t *testing.T) {
+	lines := smartquery.NewOptionSliceField("lines", func(o orderStruct) ([]string, bool) {
+		if o.Lines == nil {
+			return nil, false
+		}
+		return *o.Lines, true
+	})
+	q := lines.Where(smartquery.AnyElement[string](smartquery.LikeString("X%").AsRef()))
+
+	orders := []orderStruct{
+		{1, &[]string{"X-1", "Y-1"}},
+		{2, &[]string{"Y-2"}},
+		{3, nil},
+	}
+	found, err := smartquery.Filter(orders, q.AsRef())
+	assert.NilError(t, err)
+	assert.Equal(t, len(found), 1)
+	assert.Equal(t, found[0].ID, 1)
+
+	// None slices never match, even when the slice query would match an empty slice
+	empty := lines.Where(smartquery.NoElement[string](smartquery.AlwaysString().AsRef()))
+	matches, err := empty.Matches(orders[2])
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Slice predicate matched a None slice!")
+
+	plain := smartquery.NewSliceField("lines", func(o orderStruct) []string { return *o.Lines })
+	some := optional.NewOption(orders[1])
+	none := optional.None[orderStruct]()
+	matches, err = plain.Where(smartquery.LenBetween[string](1, 1)).AsRef().MatchesOption(&some)
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Slice predicate did not match an option record!")
+
+	matches, err = plain.Where(smartquery.LenBetween[string](0, 1)).AsRef().MatchesOption(&none)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Slice predicate matched a None record!")
+}
@@ -0,0 +1,162 @@
+package query
+
+import (
+	"maps"
+	"slices"
+	"sync"
+)
+
+type EventKind int
+
+const (
+	EventInsert EventKind = iota
+	EventUpdate
+	EventDelete
+)
+
+// Event is a change to a record in some stream of changes. ID identifies the record across events. Record is the new
+// value of the record and is ignored for deletes.
+//
+// Changed optionally lists the names of the fields which changed in an update. When it is nil every field is assumed
+// to have changed. When it is not nil and none of the fields the subscription watches overlap with it (see Overlaps),
+// the query is not evaluated again, as long as the subscription has seen the record before and so knows whether it
+// matched.
+type Event[K comparable, T comparable] struct {
+	Kind    EventKind
+	ID      K
+	Record  T
+	Changed []string
+}
+
+type NotificationKind int
+
+const (
+	NotifyEnter  NotificationKind = iota // A record started matching
+	NotifyLeave                          // A record stopped matching, or was deleted while it matched
+	NotifyUpdate                         // A record which matched was changed and still matches
+)
+
+// Notification describes how an event changed the set of matching records. Old is the zero value for NotifyEnter and
+// New is the zero value for NotifyLeave.
+type Notification[K comparable, T comparable] struct {
+	Kind NotificationKind
+	ID   K
+	Old  T
+	New  T
+}
+
+// Subscription maintains the set of records matching a query over a stream of insert, update and delete events, such
+// as a change data capture feed, and reports how each event changes that set. Only the changed record is evaluated for
+// each event. It keeps the records which currently match, and the IDs of the ones which don't so that it knows their
+// state as well.
+//
+// Inserts and updates are handled the same way, so replaying an event or receiving an update for a record which was
+// never inserted does no harm. A Subscription is safe to use from multiple goroutines.
+type Subscription[K comparable, T comparable] struct {
+	lock    sync.Mutex
+	query   Query[T]
+	matched map[K]T
+	// IDs of records which have been seen but don't match
+	unmatched map[K]struct{}
+	fields    []string
+}
+
+func NewSubscription[K comparable, T comparable](query Query[T]) *Subscription[K, T] {
+	return &Subscription[K, T]{query: query, matched: make(map[K]T), unmatched: make(map[K]struct{})}
+}
+
+// Watching sets the names of the fields the query reads, so updates which only touch other fields can skip evaluation.
+// Without it the fields come from Dependencies, and if those aren't complete the Changed list of events is ignored.
+// Getting this wrong means missed notifications, so only list fields you are sure about.
+func (s *Subscription[K, T]) Watching(fields ...string) *Subscription[K, T] {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.fields = slices.Clone(fields)
+	return s
+}
+
+// Apply processes one event. It returns the notification for the event, or false if the event did not change the
+// set of matching records. Errors from the query are returned without changing the set.
+func (s *Subscription[K, T]) Apply(event Event[K, T]) (Notification[K, T], bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	old, wasMatched := s.matched[event.ID]
+	_, wasUnmatched := s.unmatched[event.ID]
+	if event.Kind == EventDelete {
+		delete(s.unmatched, event.ID)
+		if !wasMatched {
+			return Notification[K, T]{}, false, nil
+		}
+		delete(s.matched, event.ID)
+		return Notification[K, T]{Kind: NotifyLeave, ID: event.ID, Old: old}, true, nil
+	}
+
+	var matched bool
+	if event.Kind == EventUpdate && (wasMatched || wasUnmatched) && s.skip(event.Changed) {
+		// Nothing the query reads has changed, so neither has the result
+		matched = wasMatched
+	} else {
+		var err error
+		matched, err = s.query.Matches(event.Record)
+		if err != nil {
+			return Notification[K, T]{}, false, err
+		}
+	}
+
+	if matched {
+		delete(s.unmatched, event.ID)
+		s.matched[event.ID] = event.Record
+		if wasMatched {
+			return Notification[K, T]{Kind: NotifyUpdate, ID: event.ID, Old: old, New: event.Record}, true, nil
+		}
+		return Notification[K, T]{Kind: NotifyEnter, ID: event.ID, New: event.Record}, true, nil
+	}
+	s.unmatched[event.ID] = struct{}{}
+	if wasMatched {
+		delete(s.matched, event.ID)
+		return Notification[K, T]{Kind: NotifyLeave, ID: event.ID, Old: old}, true, nil
+	}
+	return Notification[K, T]{}, false, nil
+}
+
+func (s *Subscription[K, T]) skip(changed []string) bool {
+	if changed == nil {
+		return false
+	}
+	fields := s.fields
+	if fields == nil {
+		// Worked out for every event since references in the query can be redefined at any time
+		var complete bool
+		fields, complete = Dependencies(s.query)
+		if !complete {
+			return false
+		}
+	}
+	return !Overlaps(fields, changed)
+}
+
+func (s *Subscription[K, T]) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return len(s.matched)
+}
+
+// Get returns the current value of a matching record.
+func (s *Subscription[K, T]) Get(id K) (T, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	record, ok := s.matched[id]
+	return record, ok
+}
+
+// Matched returns a copy of the current set of matching records.
+func (s *Subscription[K, T]) Matched() map[K]T {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return maps.Clone(s.matched)
+}
@@ -0,0 +1,121 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestSubscription(t *testing.T) {
+	counter := countingQuery[testStruct]{query: balanceField.Where(smartquery.AtLeast(100).AsRef()).AsRef()}
+	sub := smartquery.NewSubscription[int, testStruct](&counter).Watching("balance")
+
+	record := func(balance int) testStruct {
+		return testStruct{Name: "Chester", Email: optional.None[string]().AsRef(), Balance: balance, Stars: optional.None[int]().AsRef()}
+	}
+
+	steps := []struct {
+		name   string
+		event  smartquery.Event[int, testStruct]
+		notify bool
+		kind   smartquery.NotificationKind
+		evals  int
+	}{
+		{"insert unmatched", smartquery.Event[int, testStruct]{Kind: smartquery.EventInsert, ID: 1, Record: record(50)}, false, 0, 1},
+		{"update enters", smartquery.Event[int, testStruct]{Kind: smartquery.EventUpdate, ID: 1, Record: record(150)}, true, smartquery.NotifyEnter, 2},
+		{"update stays", smartquery.Event[int, testStruct]{Kind: smartquery.EventUpdate, ID: 1, Record: record(200)}, true, smartquery.NotifyUpdate, 3},
+		{"unrelated field", smartquery.Event[int, testStruct]{Kind: smartquery.EventUpdate, ID: 1, Record: record(200), Changed: []string{"name"}}, true, smartquery.NotifyUpdate, 3},
+		{"update leaves", smartquery.Event[int, testStruct]{Kind: smartquery.EventUpdate, ID: 1, Record: record(10), Changed: []string{"balance"}}, true, smartquery.NotifyLeave, 4},
+		{"unrelated field unmatched", smartquery.Event[int, testStruct]{Kind: smartquery.EventUpdate, ID: 1, Record: record(10), Changed: []string{"name"}}, false, 0, 4},
+		{"insert matched", smartquery.Event[int, testStruct]{Kind: smartquery.EventInsert, ID: 2, Record: record(500)}, true, smartquery.NotifyEnter, 5},
+		{"delete unmatched", smartquery.Event[int, testStruct]{Kind: smartquery.EventDelete, ID: 1}, false, 0, 5},
+		{"delete matched", smartquery.Event[int, testStruct]{Kind: smartquery.EventDelete, ID: 2}, true, smartquery.NotifyLeave, 5},
+		{"unrelated field unseen", smartquery.Event[int, testStruct]{Kind: smartquery.EventUpdate, ID: 4, Record: record(300), Changed: []string{"name"}}, true, smartquery.NotifyEnter, 6},
+		{"unrelated field deleted", smartquery.Event[int, testStruct]{Kind: smartquery.EventUpdate, ID: 1, Record: record(300), Changed: []string{"name"}}, true, smartquery.NotifyEnter, 7},
+		{"delete unseen", smartquery.Event[int, testStruct]{Kind: smartquery.EventDelete, ID: 4}, true, smartquery.NotifyLeave, 7},
+		{"delete again", smartquery.Event[int, testStruct]{Kind: smartquery.EventDelete, ID: 1}, true, smartquery.NotifyLeave, 7},
+	}
+
+	for _, step := range steps {
+		n, ok, err := sub.Apply(step.event)
+		assert.NilError(t, err, step.name)
+		assert.Equal(t, ok, step.notify, step.name)
+		assert.Equal(t, counter.calls, step.evals, "%s: wrong number of evaluations", step.name)
+		if ok {
+			assert.Equal(t, n.Kind, step.kind, step.name)
+			assert.Equal(t, n.ID, step.event.ID, step.name)
+		}
+	}
+	assert.Equal(t, sub.Len(), 0)
+
+	// Notifications carry the old and new records
+	_, _, err := sub.Apply(smartquery.Event[int, testStruct]{Kind: smartquery.EventInsert, ID: 3, Record: record(100)})
+	assert.NilError(t, err)
+	n, _, err := sub.Apply(smartquery.Event[int, testStruct]{Kind: smartquery.EventUpdate, ID: 3, Record: record(101)})
+	assert.NilError(t, err)
+	assert.Equal(t, n.Old.Balance, 100)
+	assert.Equal(t, n.New.Balance, 101)
+
+	current, ok := sub.Get(3)
+	assert.Assert(t, ok)
+	assert.Equal(t, current.Balance, 101)
+	assert.Equal(t, len(sub.Matched()), 1)
+}
+
+func TestSubscriptionWithoutFields(t *testing.T) {
+	counter := countingQuery[testStruct]{query: balanceField.Where(smartquery.AtLeast(100).AsRef()).AsRef()}
+	sub := smartquery.NewSubscription[string, testStruct](&counter)
+	s := testStruct{Name: "Chester", Email: optional.None[string]().AsRef(), Balance: 100, Stars: optional.None[int]().AsRef()}
+
+	_, _, err := sub.Apply(smartquery.Event[string, testStruct]{Kind: smartquery.EventInsert, ID: "chester", Record: s})
+	assert.NilError(t, err)
+
+	// The counting wrapper hides which fields the query reads, so the change list can't be trusted to skip anything
+	s.Balance = 0
+	n, ok, err := sub.Apply(smartquery.Event[string, testStruct]{Kind: smartquery.EventUpdate, ID: "chester", Record: s, Changed: []string{"name"}})
+	assert.NilError(t, err)
+	assert.Assert(t, ok)
+	assert.Equal(t, n.Kind, smartquery.NotifyLeave)
+	assert.Equal(t, counter.calls, 2)
+}
+
+func TestSubscriptionDependencies(t *testing.T) {
+	sub := smartquery.NewSubscription[int, testStruct](balanceField.Where(smartquery.AtLeast(100).AsRef()).AsRef())
+	s := testStruct{Name: "Chester", Email: optional.None[string]().AsRef(), Balance: 100, Stars: optional.None[int]().AsRef()}
+
+	_, _, err := sub.Apply(smartquery.Event[int, testStruct]{Kind: smartquery.EventInsert, ID: 1, Record: s})
+	assert.NilError(t, err)
+
+	// The query only reads balance, so a change list without it means the result can't have changed
+	s.Balance = 0
+	n, _, err := sub.Apply(smartquery.Event[int, testStruct]{Kind: smartquery.EventUpdate, ID: 1, Record: s, Changed: []string{"name"}})
+	assert.NilError(t, err)
+	assert.Equal(t, n.Kind, smartquery.NotifyUpdate)
+
+	n, _, err = sub.Apply(smartquery.Event[int, testStruct]{Kind: smartquery.EventUpdate, ID: 1, Record: s, Changed: []string{"balance"}})
+	assert.NilError(t, err)
+	assert.Equal(t, n.Kind, smartquery.NotifyLeave)
+}
+
+func TestSubscriptionNestedFields(t *testing.T) {
+	city := smartquery.Path[testCustomer]("Address.City", smartquery.ExactString("Paris").AsRef())
+	sub := smartquery.NewSubscription[int, testCustomer](city.AsRef())
+	c := testCustomer{Name: "Chester", Address: testAddress{City: "Paris"}}
+
+	_, _, err := sub.Apply(smartquery.Event[int, testCustomer]{Kind: smartquery.EventInsert, ID: 1, Record: c})
+	assert.NilError(t, err)
+
+	// Replacing the whole address changes the city as well
+	c.Address = testAddress{City: "Lyon"}
+	n, ok, err := sub.Apply(smartquery.Event[int, testCustomer]{Kind: smartquery.EventUpdate, ID: 1, Record: c, Changed: []string{"Address"}})
+	assert.NilError(t, err)
+	assert.Assert(t, ok)
+	assert.Equal(t, n.Kind, smartquery.NotifyLeave)
+
+	c.Name = "Mittens"
+	_, ok, err = sub.Apply(smartquery.Event[int, testCustomer]{Kind: smartquery.EventUpdate, ID: 1, Record: c, Changed: []string{"Name"}})
+	assert.NilError(t, err)
+	assert.Assert(t, !ok)
+}
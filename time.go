@@ -0,0 +1,169 @@
+package query
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brnsampson/optional"
+)
+
+type TimeMatchType int
+
+const (
+	// Time matching operations. The relative ones (Within, OlderThan and Day) are evaluated against the query's Clock
+	// every time they are matched, not when the query is created.
+	MatchWithin    TimeMatchType = iota // True if the time is no more than a duration before now. Future times match.
+	MatchOlderThan                      // True if the time is more than a duration before now
+	MatchBefore                         // True if the time is before a fixed instant
+	MatchAfter                          // True if the time is after a fixed instant
+	MatchDay                            // True if the time is on the calendar day offset by some number of days from today
+	MatchWeekday                        // True if the time falls on one of a set of weekdays
+	MatchHour                           // True if the hour of the time is in a range
+	MatchMonth                          // True if the time falls in one of a set of months
+)
+
+// TimeQuery matches time.Time values, either relative to the current time of a Clock or by calendar properties such as
+// the weekday. Calendar properties and day boundaries are calculated in the location set with In. Without one, calendar
+// properties use the location of the time being matched and day boundaries use the location of the clock's time.
+// None never matches.
+type TimeQuery struct {
+	criteria TimeMatchType
+	duration time.Duration
+	instant  time.Time
+	days     int
+	weekdays uint8
+	months   uint16
+	fromHour int
+	toHour   int
+	location *time.Location
+	clock    Clock
+}
+
+// Within matches times no older than d. Times in the future also match, so Within and OlderThan are complements.
+func Within(d time.Duration) TimeQuery {
+	return TimeQuery{criteria: MatchWithin, duration: d}
+}
+
+func OlderThan(d time.Duration) TimeQuery {
+	return TimeQuery{criteria: MatchOlderThan, duration: d}
+}
+
+func Before(instant time.Time) TimeQuery {
+	return TimeQuery{criteria: MatchBefore, instant: instant}
+}
+
+func After(instant time.Time) TimeQuery {
+	return TimeQuery{criteria: MatchAfter, instant: instant}
+}
+
+// Today matches times between the start of today and the start of tomorrow.
+func Today() TimeQuery {
+	return DaysAgo(0)
+}
+
+// DaysAgo matches times on the calendar day n days before today. Days are calendar days, so they may be 23 or 25 hours
+// long around daylight saving time changes.
+func DaysAgo(n int) TimeQuery {
+	return TimeQuery{criteria: MatchDay, days: -n}
+}
+
+func OnWeekdays(days ...time.Weekday) TimeQuery {
+	q := TimeQuery{criteria: MatchWeekday}
+	for _, d := range days {
+		q.weekdays |= 1 << d
+	}
+	return q
+}
+
+// HourBetween matches times from the start of hour from up to (but not including) hour to. If to is less than from the
+// range wraps around midnight, so HourBetween(22, 6) matches the night.
+func HourBetween(from, to int) TimeQuery {
+	return TimeQuery{criteria: MatchHour, fromHour: from, toHour: to}
+}
+
+func InMonths(months ...time.Month) TimeQuery {
+	q := TimeQuery{criteria: MatchMonth}
+	for _, m := range months {
+		q.months |= 1 << m
+	}
+	return q
+}
+
+// In returns a copy of the query which evaluates calendar properties and day boundaries in the given location.
+func (q TimeQuery) In(location *time.Location) TimeQuery {
+	q.location = location
+	return q
+}
+
+// WithClock returns a copy of the query which reads the current time from the given clock. Without a clock of its own
+// the query uses the clock of the EvalContext it is evaluated in, or the SystemClock.
+func (q TimeQuery) WithClock(clock Clock) TimeQuery {
+	q.clock = clock
+	return q
+}
+
+func (q TimeQuery) AsRef() *TimeQuery {
+	return &q
+}
+
+// now reads the current time from the query's own clock if it has one, and from the context's clock otherwise.
+func (q *TimeQuery) now(ctx *EvalContext) time.Time {
+	if q.clock == nil {
+		return ctx.Now()
+	}
+	return q.clock.Now()
+}
+
+func (q *TimeQuery) in(t time.Time) time.Time {
+	if q.location == nil {
+		return t
+	}
+	return t.In(q.location)
+}
+
+func (q *TimeQuery) Matches(value time.Time) (bool, error) {
+	return q.MatchesContext(nil, value)
+}
+
+func (q *TimeQuery) MatchesOption(value optional.Optional[time.Time]) (bool, error) {
+	return q.MatchesOptionContext(nil, value)
+}
+
+func (q *TimeQuery) MatchesContext(ctx *EvalContext, value time.Time) (bool, error) {
+	c := q.criteria
+	if c == MatchWithin {
+		return !value.Before(q.now(ctx).Add(-q.duration)), nil
+	} else if c == MatchOlderThan {
+		return value.Before(q.now(ctx).Add(-q.duration)), nil
+	} else if c == MatchBefore {
+		return value.Before(q.instant), nil
+	} else if c == MatchAfter {
+		return value.After(q.instant), nil
+	} else if c == MatchDay {
+		now := q.in(q.now(ctx))
+		start := time.Date(now.Year(), now.Month(), now.Day()+q.days, 0, 0, 0, 0, now.Location())
+		end := time.Date(now.Year(), now.Month(), now.Day()+q.days+1, 0, 0, 0, 0, now.Location())
+		return !value.Before(start) && value.Before(end), nil
+	} else if c == MatchWeekday {
+		return q.weekdays&(1<<q.in(value).Weekday()) != 0, nil
+	} else if c == MatchHour {
+		if q.fromHour < 0 || q.fromHour > 24 || q.toHour < 0 || q.toHour > 24 {
+			return false, fmt.Errorf("QueryError: invalid hour range %d to %d", q.fromHour, q.toHour)
+		}
+		hour := q.in(value).Hour()
+		if q.fromHour <= q.toHour {
+			return hour >= q.fromHour && hour < q.toHour, nil
+		}
+		return hour >= q.fromHour || hour < q.toHour, nil
+	} else if c == MatchMonth {
+		return q.months&(1<<q.in(value).Month()) != 0, nil
+	}
+	return false, fmt.Errorf("QueryError: unsupported time matching strategy: %d", c)
+}
+
+func (q *TimeQuery) MatchesOptionContext(ctx *EvalContext, value optional.Optional[time.Time]) (bool, error) {
+	if value.IsNone() {
+		return false, nil
+	}
+	return q.MatchesContext(ctx, value.UnsafeUnwrap())
+}
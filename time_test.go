@@ -0,0 +1,125 @@
+package query_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brnsampson/optional"
+	"github.com/brnsampson/smartquery"
+	"gotest.tools/v3/assert"
+)
+
+func TestRelativeTimeQuery(t *testing.T) {
+	now := time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+	clock := smartquery.FixedClock(now)
+
+	within := smartquery.Within(24 * time.Hour).WithClock(clock)
+	older := smartquery.OlderThan(7 * 24 * time.Hour).WithClock(clock)
+
+	cases := []struct {
+		name    string
+		query   smartquery.TimeQuery
+		value   time.Time
+		matches bool
+	}{
+		{"within", within, now.Add(-time.Hour), true},
+		{"within boundary", within, now.Add(-24 * time.Hour), true},
+		{"within too old", within, now.Add(-25 * time.Hour), false},
+		{"within future", within, now.Add(time.Hour), true},
+		{"older", older, now.Add(-8 * 24 * time.Hour), true},
+		{"older boundary", older, now.Add(-7 * 24 * time.Hour), false},
+		{"before", smartquery.Before(now), now.Add(-time.Second), true},
+		{"before same", smartquery.Before(now), now, false},
+		{"after", smartquery.After(now), now.Add(time.Second), true},
+		{"today", smartquery.Today().WithClock(clock), time.Date(2024, time.June, 15, 0, 0, 0, 0, time.UTC), true},
+		{"today end", smartquery.Today().WithClock(clock), time.Date(2024, time.June, 16, 0, 0, 0, 0, time.UTC), false},
+		{"yesterday", smartquery.DaysAgo(1).WithClock(clock), time.Date(2024, time.June, 14, 23, 59, 0, 0, time.UTC), true},
+	}
+
+	for _, c := range cases {
+		some := optional.NewOption(c.value)
+		none := optional.None[time.Time]()
+
+		matches, err := c.query.Matches(c.value)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, c.name)
+
+		matches, err = c.query.MatchesOption(&some)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, c.name)
+
+		matches, err = c.query.MatchesOption(&none)
+		assert.NilError(t, err)
+		assert.Assert(t, !matches, "%s: time query matched option with None value!", c.name)
+	}
+
+	// The clock is read when the query is evaluated, not when it is created
+	current := now
+	moving := smartquery.Within(time.Hour).WithClock(smartquery.ClockFunc(func() time.Time { return current }))
+	matches, err := moving.Matches(now)
+	assert.NilError(t, err)
+	assert.Assert(t, matches)
+	current = now.Add(2 * time.Hour)
+	matches, err = moving.Matches(now)
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "Within query did not use the current time of its clock!")
+}
+
+func TestCalendarTimeQuery(t *testing.T) {
+	// Saturday in UTC, but already Sunday in Tokyo
+	saturday := time.Date(2024, time.June, 15, 22, 30, 0, 0, time.UTC)
+	tokyo := time.FixedZone("JST", 9*60*60)
+
+	cases := []struct {
+		name    string
+		query   smartquery.TimeQuery
+		matches bool
+	}{
+		{"weekday", smartquery.OnWeekdays(time.Saturday, time.Sunday), true},
+		{"weekday miss", smartquery.OnWeekdays(time.Monday), false},
+		{"weekday in location", smartquery.OnWeekdays(time.Sunday).In(tokyo), true},
+		{"hour", smartquery.HourBetween(22, 23), true},
+		{"hour end is exclusive", smartquery.HourBetween(20, 22), false},
+		{"hour wraps", smartquery.HourBetween(21, 6), true},
+		{"hour in location", smartquery.HourBetween(7, 8).In(tokyo), true},
+		{"month", smartquery.InMonths(time.May, time.June), true},
+		{"month miss", smartquery.InMonths(time.July), false},
+	}
+
+	for _, c := range cases {
+		matches, err := c.query.Matches(saturday)
+		assert.NilError(t, err)
+		assert.Equal(t, matches, c.matches, c.name)
+	}
+
+	_, err := smartquery.HourBetween(0, 25).AsRef().Matches(saturday)
+	assert.ErrorContains(t, err, "QueryError")
+}
+
+func TestTimeQueryDayBoundaries(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	// 20:00 UTC on the 15th is 05:00 on the 16th in Tokyo
+	clock := smartquery.FixedClock(time.Date(2024, time.June, 15, 20, 0, 0, 0, time.UTC))
+
+	today := smartquery.Today().WithClock(clock).In(tokyo)
+	matches, err := today.Matches(time.Date(2024, time.June, 15, 16, 0, 0, 0, time.UTC))
+	assert.NilError(t, err)
+	assert.Assert(t, matches, "Midnight on the 16th in Tokyo should be today!")
+
+	matches, err = today.Matches(time.Date(2024, time.June, 15, 14, 59, 0, 0, time.UTC))
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "23:59 on the 15th in Tokyo should not be today!")
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone database available")
+	}
+	// Clocks spring forward on the 10th of March 2024, so that day is only 23 hours long
+	dst := smartquery.Today().WithClock(smartquery.FixedClock(time.Date(2024, time.March, 10, 12, 0, 0, 0, newYork))).In(newYork)
+	matches, err = dst.Matches(time.Date(2024, time.March, 10, 23, 30, 0, 0, newYork))
+	assert.NilError(t, err)
+	assert.Assert(t, matches)
+	matches, err = dst.Matches(time.Date(2024, time.March, 10, 0, 0, 0, 0, newYork).Add(23 * time.Hour))
+	assert.NilError(t, err)
+	assert.Assert(t, !matches, "The 10th of March should end 23 hours after it starts in New York!")
+}